@@ -0,0 +1,241 @@
+// Package client provides a magnapinna client that balances
+// Register/CheckRegistration/Deregister calls across multiple servers with
+// active health checking, modeled on the etcd clientv3 health balancer: an
+// endpoint is marked unhealthy the moment a call against it fails with
+// Unavailable or DeadlineExceeded, held out of rotation for a configurable
+// duration, and then probed with a cheap CheckRegistration call before being
+// trusted with real traffic again.
+//
+// This is a deliberate deviation from etcd clientv3's approach, though: the
+// health balancer there is a grpc/balancer.Picker registered with gRPC's own
+// resolver/balancer machinery, reachable through an ordinary grpc.Dial with
+// a service config. Client instead dials each endpoint as its own
+// independent grpc.ClientConn and does the endpoint selection/failover
+// itself in application code (see endpoint.go and Client.call). That keeps
+// the health/failover logic simple and easy to follow at the cost of not
+// integrating with gRPC's connection/backoff machinery or being usable
+// through a plain grpc.Dial - callers need Client itself, not just a dial
+// target.
+package client
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	"magnapinna/api"
+)
+
+// defaultTimeout bounds an individual RPC attempt if Config.Timeout is
+// unset.
+const defaultTimeout = 5 * time.Second
+
+// defaultFailoverTimeout bounds a whole Register/CheckRegistration/Deregister
+// call, across every endpoint it tries, if Config.FailoverTimeout is unset.
+const defaultFailoverTimeout = 10 * time.Second
+
+// defaultUnhealthyHold is how long an endpoint is held out of rotation
+// after a connectivity failure if Config.UnhealthyHold is unset.
+const defaultUnhealthyHold = 5 * time.Second
+
+// probeIdentifier is used for the active health-check CheckRegistration
+// call. Its value never reaches a real lookup that matters: the probe only
+// cares whether the endpoint answered at all, not what it answered.
+const probeIdentifier = "__magnapinna_client_health_probe__"
+
+// Config configures a Client.
+type Config struct {
+	// Endpoints are the "host:port" addresses of the magnapinna servers to
+	// balance across. At least one is required.
+	Endpoints []string
+	// Timeout bounds each individual RPC attempt against a single endpoint.
+	// Defaults to defaultTimeout if zero.
+	Timeout time.Duration
+	// FailoverTimeout bounds how long a single call will keep trying other
+	// endpoints before giving up. Defaults to defaultFailoverTimeout if
+	// zero.
+	FailoverTimeout time.Duration
+	// UnhealthyHold is how long an endpoint that failed with Unavailable or
+	// DeadlineExceeded is skipped before being actively re-probed. Defaults
+	// to defaultUnhealthyHold if zero.
+	UnhealthyHold time.Duration
+	// DialOptions are passed through to grpc.Dial for every endpoint, in
+	// addition to insecure transport credentials.
+	DialOptions []grpc.DialOption
+}
+
+// Client balances calls across Config.Endpoints, routing each to the next
+// healthy endpoint in round-robin order. It holds one grpc.ClientConn per
+// endpoint rather than presenting a single balanced connection through a
+// custom balancer.Picker - see the package doc comment for why.
+type Client struct {
+	endpoints []*endpoint
+	cfg       Config
+
+	mut    sync.Mutex
+	cursor int
+}
+
+// New dials every endpoint in cfg.Endpoints and returns a ready-to-use
+// Client.
+func New(cfg Config) (*Client, error) {
+	if len(cfg.Endpoints) == 0 {
+		return nil, fmt.Errorf("client: at least one endpoint is required")
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	if cfg.FailoverTimeout == 0 {
+		cfg.FailoverTimeout = defaultFailoverTimeout
+	}
+	if cfg.UnhealthyHold == 0 {
+		cfg.UnhealthyHold = defaultUnhealthyHold
+	}
+
+	dialOpts := append([]grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}, cfg.DialOptions...)
+
+	endpoints := make([]*endpoint, 0, len(cfg.Endpoints))
+	for _, addr := range cfg.Endpoints {
+		conn, err := grpc.Dial(addr, dialOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("client: dialing %s: %w", addr, err)
+		}
+		endpoints = append(endpoints, &endpoint{addr: addr, conn: conn, client: api.NewMagnapinnaClient(conn)})
+	}
+
+	return &Client{endpoints: endpoints, cfg: cfg}, nil
+}
+
+// Close closes every underlying connection, returning the first error
+// encountered, if any.
+func (c *Client) Close() error {
+	var firstErr error
+	for _, e := range c.endpoints {
+		if err := e.conn.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Register calls Register against the next healthy endpoint, failing over
+// to another endpoint if the call fails with a connectivity-class error.
+func (c *Client) Register(ctx context.Context, rs *api.Registration) (*api.Lease, error) {
+	return c.call(ctx, func(ctx context.Context, mc api.MagnapinnaClient) (*api.Lease, error) {
+		return mc.Register(ctx, rs)
+	})
+}
+
+// CheckRegistration calls CheckRegistration against the next healthy
+// endpoint, failing over to another endpoint if the call fails with a
+// connectivity-class error.
+func (c *Client) CheckRegistration(ctx context.Context, rs *api.Registration) (*api.Lease, error) {
+	return c.call(ctx, func(ctx context.Context, mc api.MagnapinnaClient) (*api.Lease, error) {
+		return mc.CheckRegistration(ctx, rs)
+	})
+}
+
+// Deregister calls Deregister against the next healthy endpoint, failing
+// over to another endpoint if the call fails with a connectivity-class
+// error.
+func (c *Client) Deregister(ctx context.Context, rs *api.Registration) (*api.Lease, error) {
+	return c.call(ctx, func(ctx context.Context, mc api.MagnapinnaClient) (*api.Lease, error) {
+		return mc.Deregister(ctx, rs)
+	})
+}
+
+// next returns every endpoint, starting from the next round-robin cursor
+// position, for call to try in turn.
+func (c *Client) next() []*endpoint {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	ordered := make([]*endpoint, len(c.endpoints))
+	for i := range ordered {
+		ordered[i] = c.endpoints[(c.cursor+i)%len(c.endpoints)]
+	}
+	c.cursor = (c.cursor + 1) % len(c.endpoints)
+	return ordered
+}
+
+// call invokes fn against healthy endpoints in round-robin order, probing
+// an unhealthy-but-due endpoint with a cheap CheckRegistration call before
+// trusting it with fn, and marking an endpoint unhealthy when fn itself
+// fails with a connectivity-class error. It gives up once fn succeeds,
+// ctx or the configured FailoverTimeout expires, or every endpoint has been
+// tried.
+func (c *Client) call(ctx context.Context, fn func(context.Context, api.MagnapinnaClient) (*api.Lease, error)) (*api.Lease, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.FailoverTimeout)
+	defer cancel()
+
+	var lastErr error
+	for _, e := range c.next() {
+		if ctx.Err() != nil {
+			break
+		}
+
+		if !e.healthy() {
+			if !e.dueForProbe(c.cfg.UnhealthyHold) {
+				continue
+			}
+			c.probe(ctx, e)
+			if !e.healthy() {
+				continue
+			}
+		}
+
+		rpcCtx, rpcCancel := context.WithTimeout(ctx, c.cfg.Timeout)
+		lease, err := fn(rpcCtx, e.client)
+		rpcCancel()
+
+		if err == nil {
+			e.markHealthy()
+			return lease, nil
+		}
+
+		lastErr = err
+		if !isConnectivityError(err) {
+			// the endpoint answered; this is an application-level error
+			// (e.g. ValidationError), not a reason to fail over.
+			return nil, err
+		}
+		e.markUnhealthy()
+	}
+
+	if lastErr == nil {
+		return nil, fmt.Errorf("client: no healthy endpoint available")
+	}
+	return nil, lastErr
+}
+
+// probe issues a cheap CheckRegistration call against e to determine
+// whether it has recovered, marking it healthy again unless the probe
+// itself fails with a connectivity-class error.
+func (c *Client) probe(ctx context.Context, e *endpoint) {
+	rpcCtx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
+	_, err := e.client.CheckRegistration(rpcCtx, &api.Registration{Identifier: probeIdentifier, Duration: 1})
+	cancel()
+
+	if err == nil || !isConnectivityError(err) {
+		e.markHealthy()
+		return
+	}
+	e.markUnhealthy()
+}
+
+// isConnectivityError reports whether err indicates the endpoint itself is
+// unreachable, rather than the RPC failing for an application reason.
+func isConnectivityError(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return true
+	default:
+		return false
+	}
+}
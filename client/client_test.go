@@ -0,0 +1,99 @@
+package client_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"magnapinna/api"
+	"magnapinna/client"
+	"magnapinna/config"
+	"magnapinna/repository/memory"
+	"magnapinna/rpc"
+)
+
+// startServer starts a real magnapinna server listening on an ephemeral
+// localhost port, stopping it automatically at the end of the test.
+func startServer(t *testing.T) (addr string, srv *rpc.Server) {
+	t.Helper()
+
+	srv, err := rpc.NewServer(config.ServerConfig{
+		ListenAddr: "127.0.0.1:0",
+		RPCTimeout: config.Duration{Duration: time.Second},
+	}, memory.New())
+	if err != nil {
+		t.Fatalf("failed to build server: %s", err.Error())
+	}
+	if err := srv.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start server: %s", err.Error())
+	}
+	t.Cleanup(func() { srv.Stop() })
+
+	return srv.Addr().String(), srv
+}
+
+func TestClientFailsOverToSurvivingServer(t *testing.T) {
+	addrA, srvA := startServer(t)
+	addrB, _ := startServer(t)
+
+	c, err := client.New(client.Config{
+		Endpoints:       []string{addrA, addrB},
+		Timeout:         time.Second,
+		FailoverTimeout: 2 * time.Second,
+		UnhealthyHold:   50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("failed to build client: %s", err.Error())
+	}
+	defer c.Close()
+
+	reg := &api.Registration{Identifier: "foo", Duration: 1000}
+	if _, err := c.Register(context.Background(), reg); err != nil {
+		t.Fatalf("unexpected error before failover: %s", err.Error())
+	}
+
+	srvA.Stop()
+
+	deadline := time.Now().Add(5 * time.Second)
+	var lastErr error
+	for time.Now().Before(deadline) {
+		if _, err := c.Register(context.Background(), reg); err == nil {
+			return
+		} else {
+			lastErr = err
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("expected a call to eventually succeed against the surviving server, last error: %v", lastErr)
+}
+
+func TestClientKeepsFailingOverWhileEndpointUnhealthy(t *testing.T) {
+	addrA, srvA := startServer(t)
+	addrB, _ := startServer(t)
+
+	c, err := client.New(client.Config{
+		Endpoints:       []string{addrA, addrB},
+		Timeout:         time.Second,
+		FailoverTimeout: 2 * time.Second,
+		UnhealthyHold:   50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("failed to build client: %s", err.Error())
+	}
+	defer c.Close()
+
+	reg := &api.Registration{Identifier: "foo", Duration: 1000}
+
+	srvA.Stop()
+	if _, err := c.Register(context.Background(), reg); err != nil {
+		t.Fatalf("expected failover to addrB to succeed, got: %s", err.Error())
+	}
+
+	// addrA stays unreachable, so subsequent calls should keep succeeding
+	// via addrB without ever blocking on addrA's UnhealthyHold window.
+	for i := 0; i < 5; i++ {
+		if _, err := c.Register(context.Background(), reg); err != nil {
+			t.Fatalf("unexpected error on repeat call: %s", err.Error())
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package client
+
+import (
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"magnapinna/api"
+)
+
+// endpoint tracks one magnapinna server's connection and health state.
+// unhealthySince is the zero time while the endpoint is considered healthy;
+// set to the time of its first connectivity failure since it was last
+// healthy otherwise.
+type endpoint struct {
+	addr   string
+	conn   *grpc.ClientConn
+	client api.MagnapinnaClient
+
+	mut            sync.Mutex
+	unhealthySince time.Time
+}
+
+// healthy reports whether e is currently eligible for ordinary traffic.
+func (e *endpoint) healthy() bool {
+	e.mut.Lock()
+	defer e.mut.Unlock()
+	return e.unhealthySince.IsZero()
+}
+
+// dueForProbe reports whether e has been unhealthy for at least hold, and
+// so should be given a chance to recover via an active health probe.
+func (e *endpoint) dueForProbe(hold time.Duration) bool {
+	e.mut.Lock()
+	defer e.mut.Unlock()
+	return !e.unhealthySince.IsZero() && time.Since(e.unhealthySince) >= hold
+}
+
+// markUnhealthy records that e just failed with a connectivity-class error,
+// taking it out of rotation until it is next due for a probe.
+func (e *endpoint) markUnhealthy() {
+	e.mut.Lock()
+	defer e.mut.Unlock()
+	if e.unhealthySince.IsZero() {
+		e.unhealthySince = time.Now()
+	}
+}
+
+// markHealthy returns e to ordinary rotation.
+func (e *endpoint) markHealthy() {
+	e.mut.Lock()
+	defer e.mut.Unlock()
+	e.unhealthySince = time.Time{}
+}
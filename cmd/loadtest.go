@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"magnapinna/api"
+	"magnapinna/loadtest"
+)
+
+// runLoadtest implements the `magnapinna loadtest` subcommand: it parses a
+// loadtest.Config from -config (a path, or "-" for stdin), dials the target
+// server, fans workers out across a loadtest.Harness, and writes the
+// resulting loadtest.Report to stdout as JSON.
+func runLoadtest(args []string) error {
+	fs := flag.NewFlagSet("loadtest", flag.ExitOnError)
+	configPath := fs.String("config", "", `path to a JSON config file, or "-" to read from stdin`)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *configPath == "" {
+		return fmt.Errorf("loadtest: -config is required")
+	}
+
+	var r io.Reader
+	if *configPath == "-" {
+		r = os.Stdin
+	} else {
+		f, err := os.Open(*configPath)
+		if err != nil {
+			return fmt.Errorf("loadtest: opening config: %w", err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	cfg, err := loadtest.ParseConfig(r)
+	if err != nil {
+		return fmt.Errorf("loadtest: %w", err)
+	}
+
+	conn, err := grpc.Dial(cfg.Addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return fmt.Errorf("loadtest: dialing %s: %w", cfg.Addr, err)
+	}
+	defer conn.Close()
+	client := api.NewMagnapinnaClient(conn)
+
+	identifiers := make([]string, cfg.Cardinality)
+	for i := range identifiers {
+		identifiers[i] = fmt.Sprintf("loadtest-%d", i)
+	}
+
+	runners := make([]loadtest.Runner, cfg.Workers)
+	for i := 0; i < cfg.Workers; i++ {
+		var startDelay time.Duration
+		if cfg.Workers > 1 && cfg.RampUp > 0 {
+			startDelay = cfg.RampUp * time.Duration(i) / time.Duration(cfg.Workers)
+		}
+
+		runner, err := loadtest.NewRunner(cfg.Runner, loadtest.RunnerOpts{
+			Client:      client,
+			Identifiers: identifiers,
+			RPS:         cfg.RPS,
+			ThinkTime:   cfg.ThinkTime,
+			Timeout:     cfg.Timeout,
+			StartDelay:  startDelay,
+		})
+		if err != nil {
+			return fmt.Errorf("loadtest: %w", err)
+		}
+		runners[i] = runner
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.RampUp+cfg.Duration)
+	defer cancel()
+
+	report, err := loadtest.New(runners...).Run(ctx)
+	if err != nil {
+		return fmt.Errorf("loadtest: %w", err)
+	}
+	return json.NewEncoder(os.Stdout).Encode(report)
+}
@@ -4,6 +4,7 @@ import (
 	// "context"
 	"context"
 	"fmt"
+	"os"
 	"submersible/tty"
 	"time"
 	// "submersible/tty"
@@ -11,12 +12,19 @@ import (
 
 // Currently testing formatting, etc. for bash
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "loadtest" {
+		if err := runLoadtest(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
 
 	cmd, err := tty.NewTerminal(context.Background(), "bash", "--norc", "-i")
 	if err != nil {
 		panic("failed to start pty: " + err.Error())
 	}
-	cmd.Start()
+	cmd.Start(context.Background())
 	errs := cmd.Errors()
 	go func() {
 		for {
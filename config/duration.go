@@ -0,0 +1,31 @@
+// Package config provides the on-disk configuration shape for a magnapinna
+// Server, so operators can tune things like per-RPC timeouts and lease
+// durations from a TOML, JSON, or YAML file instead of recompiling.
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// Duration wraps time.Duration so it marshals as a string like "250ms"
+// rather than a raw integer of nanoseconds, letting the same struct tags
+// work uniformly whether the config file is TOML, JSON, or YAML.
+type Duration struct {
+	time.Duration
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.Duration.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return fmt.Errorf("parsing duration %q: %w", text, err)
+	}
+	d.Duration = parsed
+	return nil
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestDurationRoundTrip(t *testing.T) {
+	cases := []struct {
+		name string
+		in   time.Duration
+	}{
+		{name: "milliseconds", in: 250 * time.Millisecond},
+		{name: "seconds", in: 5 * time.Second},
+		{name: "zero", in: 0},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			d := Duration{Duration: c.in}
+
+			text, err := d.MarshalText()
+			if err != nil {
+				t.Fatalf("unexpected error marshaling: %s", err.Error())
+			}
+
+			var out Duration
+			if err := out.UnmarshalText(text); err != nil {
+				t.Fatalf("unexpected error unmarshaling: %s", err.Error())
+			}
+			if out.Duration != c.in {
+				t.Errorf("expected %s, got %s", c.in, out.Duration)
+			}
+		})
+	}
+}
+
+func TestDurationUnmarshalInvalid(t *testing.T) {
+	var d Duration
+	if err := d.UnmarshalText([]byte("not a duration")); err == nil {
+		t.Error("expected an error parsing an invalid duration")
+	}
+}
+
+func TestDurationJSON(t *testing.T) {
+	type wrapper struct {
+		Timeout Duration `json:"timeout"`
+	}
+
+	in := wrapper{Timeout: Duration{Duration: 3 * time.Second}}
+	data, err := json.Marshal(in)
+	if err != nil {
+		t.Fatalf("unexpected error marshaling: %s", err.Error())
+	}
+
+	var out wrapper
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatalf("unexpected error unmarshaling: %s", err.Error())
+	}
+	if out.Timeout.Duration != in.Timeout.Duration {
+		t.Errorf("expected %s, got %s", in.Timeout.Duration, out.Timeout.Duration)
+	}
+}
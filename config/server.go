@@ -0,0 +1,61 @@
+package config
+
+// RepositoryBackend selects which rpc.Repository implementation a
+// bootstrap command should construct before passing it to rpc.NewServer.
+type RepositoryBackend string
+
+const (
+	// RepositoryBackendMemory selects repository/memory, for tests and
+	// local development.
+	RepositoryBackendMemory RepositoryBackend = "memory"
+	// RepositoryBackendEtcd selects repository/etcd, for durable,
+	// cluster-shared lease storage.
+	RepositoryBackendEtcd RepositoryBackend = "etcd"
+)
+
+// EtcdConfig configures a repository/etcd.Repository, when
+// RepositoryBackend is RepositoryBackendEtcd.
+type EtcdConfig struct {
+	Endpoints   []string `toml:"endpoints" json:"endpoints" yaml:"endpoints"`
+	DialTimeout Duration `toml:"dial_timeout" json:"dial_timeout" yaml:"dial_timeout"`
+}
+
+// ObserverConfig configures a Server's structured logging, passed through
+// to logger.Setup.
+type ObserverConfig struct {
+	Level       string   `toml:"level" json:"level" yaml:"level"`
+	OutputPaths []string `toml:"output_paths" json:"output_paths" yaml:"output_paths"`
+}
+
+// ServerConfig covers every operator-tunable setting for a Server.
+type ServerConfig struct {
+	// ListenAddr is the "host:port" the gRPC server listens on.
+	ListenAddr string `toml:"listen_addr" json:"listen_addr" yaml:"listen_addr"`
+	// RPCTimeout bounds each unary RPC's server-side context.
+	RPCTimeout Duration `toml:"rpc_timeout" json:"rpc_timeout" yaml:"rpc_timeout"`
+	// LeaseDefaultDuration is used for a Registration whose Duration is
+	// unset (zero).
+	LeaseDefaultDuration Duration `toml:"lease_default_duration" json:"lease_default_duration" yaml:"lease_default_duration"`
+	// LeaseMaxDuration caps how long a single lease may be registered for,
+	// regardless of what the Registration requests. Zero means unbounded.
+	LeaseMaxDuration Duration `toml:"lease_max_duration" json:"lease_max_duration" yaml:"lease_max_duration"`
+	// ExpirationSweepInterval is how often the expiration reaper
+	// controller scans the Repository for expired leases. Zero disables
+	// the reaper.
+	ExpirationSweepInterval Duration `toml:"expiration_sweep_interval" json:"expiration_sweep_interval" yaml:"expiration_sweep_interval"`
+
+	// RepositoryBackend and the backend-specific settings below are read
+	// by bootstrap code to decide which Repository to construct; rpc.
+	// NewServer itself just takes the already-constructed Repository.
+	RepositoryBackend RepositoryBackend `toml:"repository_backend" json:"repository_backend" yaml:"repository_backend"`
+	Etcd              EtcdConfig        `toml:"etcd" json:"etcd" yaml:"etcd"`
+
+	Observer ObserverConfig `toml:"observer" json:"observer" yaml:"observer"`
+
+	// JWKSURL, if set, is used to build a JWTAuthenticator. Leave empty to
+	// run without authentication.
+	JWKSURL string `toml:"jwks_url" json:"jwks_url" yaml:"jwks_url"`
+	// UnauthenticatedMethods lists full gRPC method names (e.g.
+	// "/Magnapinna/CheckRegistration") exempt from authentication.
+	UnauthenticatedMethods []string `toml:"unauthenticated_methods" json:"unauthenticated_methods" yaml:"unauthenticated_methods"`
+}
@@ -0,0 +1,95 @@
+package loadtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Config describes a load test run against a magnapinna server, decoded
+// from the JSON file (or stdin, via "-config -") passed to `magnapinna
+// loadtest`.
+type Config struct {
+	// Addr is the server address to dial, e.g. "localhost:9090".
+	Addr string
+	// Workers is the number of concurrent goroutines driving traffic.
+	Workers int
+	// RPS is the target requests per second, per worker.
+	RPS float64
+	// Cardinality bounds how many distinct identifiers workers cycle
+	// through, so repeated runs exercise lease churn rather than an
+	// ever-growing key set.
+	Cardinality int
+	// Duration is how long the test runs once ramp-up completes.
+	Duration time.Duration
+	// RampUp spreads worker start times evenly across this window, to avoid
+	// a thundering herd of connections at t=0.
+	RampUp time.Duration
+	// ThinkTime is an optional pause a worker takes between RPCs, on top of
+	// RPS-derived pacing.
+	ThinkTime time.Duration
+	// Timeout bounds each individual RPC.
+	Timeout time.Duration
+	// Runner selects a built-in Runner by name; see NewRunner.
+	Runner string
+}
+
+// configJSON mirrors Config with duration fields as strings (e.g. "30s"),
+// since encoding/json has no notion of time.Duration.
+type configJSON struct {
+	Addr        string  `json:"addr"`
+	Workers     int     `json:"workers"`
+	RPS         float64 `json:"rps"`
+	Cardinality int     `json:"cardinality"`
+	Duration    string  `json:"duration"`
+	RampUp      string  `json:"ramp_up"`
+	ThinkTime   string  `json:"think_time"`
+	Timeout     string  `json:"timeout"`
+	Runner      string  `json:"runner"`
+}
+
+// ParseConfig decodes a Config from r.
+func ParseConfig(r io.Reader) (Config, error) {
+	var raw configJSON
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return Config{}, fmt.Errorf("decoding loadtest config: %w", err)
+	}
+
+	cfg := Config{
+		Addr:        raw.Addr,
+		Workers:     raw.Workers,
+		RPS:         raw.RPS,
+		Cardinality: raw.Cardinality,
+		Runner:      raw.Runner,
+	}
+
+	for _, d := range []struct {
+		name string
+		src  string
+		dst  *time.Duration
+	}{
+		{"duration", raw.Duration, &cfg.Duration},
+		{"ramp_up", raw.RampUp, &cfg.RampUp},
+		{"think_time", raw.ThinkTime, &cfg.ThinkTime},
+		{"timeout", raw.Timeout, &cfg.Timeout},
+	} {
+		if d.src == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(d.src)
+		if err != nil {
+			return Config{}, fmt.Errorf("parsing %s: %w", d.name, err)
+		}
+		*d.dst = parsed
+	}
+
+	if cfg.Workers <= 0 {
+		return Config{}, fmt.Errorf("loadtest config: workers must be greater than zero, got %d", cfg.Workers)
+	}
+	if cfg.Cardinality <= 0 {
+		return Config{}, fmt.Errorf("loadtest config: cardinality must be greater than zero, got %d", cfg.Cardinality)
+	}
+
+	return cfg, nil
+}
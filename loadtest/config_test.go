@@ -0,0 +1,55 @@
+package loadtest
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseConfig(t *testing.T) {
+	const body = `{
+		"addr": "localhost:9090",
+		"workers": 4,
+		"rps": 10,
+		"cardinality": 100,
+		"duration": "30s",
+		"ramp_up": "5s",
+		"think_time": "10ms",
+		"timeout": "2s",
+		"runner": "register_churn"
+	}`
+
+	cfg, err := ParseConfig(strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if cfg.Addr != "localhost:9090" || cfg.Workers != 4 || cfg.Runner != "register_churn" {
+		t.Fatalf("unexpected config: %+v", cfg)
+	}
+	if cfg.Duration != 30*time.Second || cfg.RampUp != 5*time.Second ||
+		cfg.ThinkTime != 10*time.Millisecond || cfg.Timeout != 2*time.Second {
+		t.Fatalf("unexpected durations: %+v", cfg)
+	}
+}
+
+func TestParseConfigInvalidDuration(t *testing.T) {
+	const body = `{"duration": "not-a-duration"}`
+	if _, err := ParseConfig(strings.NewReader(body)); err == nil {
+		t.Error("expected error parsing invalid duration")
+	}
+}
+
+func TestParseConfigMissingCardinality(t *testing.T) {
+	const body = `{"addr": "localhost:9090", "workers": 4, "rps": 10, "duration": "30s"}`
+	if _, err := ParseConfig(strings.NewReader(body)); err == nil {
+		t.Error("expected error for a config with no cardinality, to avoid a divide-by-zero in the runners")
+	}
+}
+
+func TestParseConfigMissingWorkers(t *testing.T) {
+	const body = `{"addr": "localhost:9090", "cardinality": 100, "rps": 10, "duration": "30s"}`
+	if _, err := ParseConfig(strings.NewReader(body)); err == nil {
+		t.Error("expected error for a config with no workers")
+	}
+}
@@ -0,0 +1,196 @@
+// Package loadtest drives concurrent Register/CheckRegistration/Deregister
+// traffic against a running magnapinna server, so that Repository
+// implementations can be benchmarked against the same unary server path
+// exercised by rpc.TestUnaryFunctions, rather than guessed at.
+package loadtest
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"magnapinna/rpc"
+)
+
+// Result is what a single Runner.Run reports back to a Harness.
+type Result struct {
+	// RPCs is the number of RPCs the Runner performed.
+	RPCs int
+	// Latencies holds one entry per RPC, measured end-to-end.
+	Latencies []time.Duration
+	// Errors classifies every failed RPC; see classifyError.
+	Errors map[string]int
+}
+
+// Runner drives one worker's worth of traffic against a magnapinna server
+// for the lifetime of ctx, returning what it observed. Run should return
+// once ctx is done rather than erroring, reserving the error return for
+// failures unrelated to individual RPCs (e.g. it never got to run at all).
+type Runner interface {
+	Run(ctx context.Context) (Result, error)
+}
+
+// Harness fans a set of Runners out across their own goroutines and
+// aggregates their Results into a Report once they've all returned.
+type Harness struct {
+	runners []Runner
+}
+
+// New returns a Harness ready to run runners concurrently.
+func New(runners ...Runner) *Harness {
+	return &Harness{runners: runners}
+}
+
+// Run starts every Runner on its own goroutine and blocks until all of them
+// have returned, which in practice means until ctx is done. It returns the
+// first error reported by any Runner, if any.
+func (h *Harness) Run(ctx context.Context) (Report, error) {
+	results := make([]Result, len(h.runners))
+	errs := make([]error, len(h.runners))
+
+	var wg sync.WaitGroup
+	wg.Add(len(h.runners))
+	for i, r := range h.runners {
+		i, r := i, r
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = r.Run(ctx)
+		}()
+	}
+
+	start := time.Now()
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	for _, err := range errs {
+		if err != nil {
+			return Report{}, err
+		}
+	}
+	return buildReport(results, elapsed), nil
+}
+
+// timeRPC calls fn with a child context bounded by timeout, recording its
+// latency and any resulting error into result. It returns false once ctx
+// itself (not just the per-RPC timeout) is done, signalling the calling
+// Runner to stop looping.
+func timeRPC(ctx context.Context, result *Result, timeout time.Duration, fn func(context.Context) error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	rpcCtx, cancel := context.WithTimeout(ctx, timeout)
+	start := time.Now()
+	err := fn(rpcCtx)
+	cancel()
+
+	result.RPCs++
+	result.Latencies = append(result.Latencies, time.Since(start))
+	if err != nil {
+		result.Errors[classifyError(err)]++
+	}
+	return ctx.Err() == nil
+}
+
+// repositoryErrorMarker is the text rpc.RepositoryError.Error() begins with,
+// and so appears in the message of a Repository error that crosses the gRPC
+// boundary, reusing rpc.RepositoryError.Sanitized() rather than duplicating
+// its text here.
+var repositoryErrorMarker = rpc.RepositoryError{}.Sanitized()
+
+// classifyError buckets an RPC error for reporting: context deadline
+// exceeded, a RepositoryError surfaced by the server, or else the raw gRPC
+// status code.
+func classifyError(err error) string {
+	if errors.Is(err, context.DeadlineExceeded) || status.Code(err) == codes.DeadlineExceeded {
+		return "deadline_exceeded"
+	}
+	if strings.Contains(err.Error(), repositoryErrorMarker) {
+		return "repository_error"
+	}
+	return status.Code(err).String()
+}
+
+// sleepCtx blocks for delay, or until ctx is done, whichever comes first,
+// returning false in the latter case. A non-positive delay returns
+// immediately, still honoring an already-done ctx.
+func sleepCtx(ctx context.Context, delay time.Duration) bool {
+	if delay <= 0 {
+		return ctx.Err() == nil
+	}
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(delay):
+		return true
+	}
+}
+
+// pacer sleeps between a Runner's iterations so it averages roughly rps
+// requests per second. An rps of zero or less means unpaced.
+type pacer struct {
+	interval time.Duration
+}
+
+func newPacer(rps float64) *pacer {
+	if rps <= 0 {
+		return &pacer{}
+	}
+	return &pacer{interval: time.Duration(float64(time.Second) / rps)}
+}
+
+// wait blocks for the pacing interval, or until ctx is done, returning false
+// in the latter case.
+func (p *pacer) wait(ctx context.Context) bool {
+	return sleepCtx(ctx, p.interval)
+}
+
+// buildReport aggregates every Runner's Result, taken over elapsed wall
+// time, into a Report.
+func buildReport(results []Result, elapsed time.Duration) Report {
+	var latencies []time.Duration
+	errs := map[string]int{}
+	rpcs := 0
+
+	for _, r := range results {
+		rpcs += r.RPCs
+		latencies = append(latencies, r.Latencies...)
+		for class, count := range r.Errors {
+			errs[class] += count
+		}
+	}
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	report := Report{
+		RPCs:     rpcs,
+		Duration: elapsed,
+		Errors:   errs,
+		P50:      percentile(latencies, 0.50),
+		P95:      percentile(latencies, 0.95),
+		P99:      percentile(latencies, 0.99),
+	}
+	if elapsed > 0 {
+		report.Throughput = float64(rpcs) / elapsed.Seconds()
+	}
+	return report
+}
+
+// percentile returns the p-th percentile (0 < p <= 1) of sorted, which must
+// already be sorted ascending, or 0 if sorted is empty.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := int(p * float64(len(sorted)))
+	if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+	return sorted[idx]
+}
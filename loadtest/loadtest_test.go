@@ -0,0 +1,64 @@
+package loadtest
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type fakeRunner struct {
+	result Result
+	err    error
+}
+
+func (f fakeRunner) Run(ctx context.Context) (Result, error) {
+	return f.result, f.err
+}
+
+func TestHarnessAggregatesResults(t *testing.T) {
+	a := fakeRunner{result: Result{
+		RPCs:      2,
+		Latencies: []time.Duration{10 * time.Millisecond, 20 * time.Millisecond},
+		Errors:    map[string]int{"deadline_exceeded": 1},
+	}}
+	b := fakeRunner{result: Result{
+		RPCs:      1,
+		Latencies: []time.Duration{30 * time.Millisecond},
+		Errors:    map[string]int{},
+	}}
+
+	report, err := New(a, b).Run(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if report.RPCs != 3 {
+		t.Errorf("expected 3 total RPCs, got %d", report.RPCs)
+	}
+	if report.Errors["deadline_exceeded"] != 1 {
+		t.Errorf("expected 1 deadline_exceeded error, got %d", report.Errors["deadline_exceeded"])
+	}
+	if report.P50 == 0 {
+		t.Errorf("expected nonzero p50 latency")
+	}
+}
+
+func TestHarnessPropagatesRunnerError(t *testing.T) {
+	boom := fakeRunner{err: context.Canceled}
+	if _, err := New(boom).Run(context.Background()); err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	sorted := []time.Duration{
+		10 * time.Millisecond, 20 * time.Millisecond, 30 * time.Millisecond,
+		40 * time.Millisecond, 50 * time.Millisecond,
+	}
+	if got := percentile(sorted, 0.5); got != 30*time.Millisecond {
+		t.Errorf("p50 = %s, want 30ms", got)
+	}
+	if got := percentile(nil, 0.5); got != 0 {
+		t.Errorf("percentile of empty slice = %s, want 0", got)
+	}
+}
@@ -0,0 +1,20 @@
+package loadtest
+
+import "time"
+
+// Report is the JSON summary a Harness run produces, printed to stdout by
+// `magnapinna loadtest`.
+type Report struct {
+	// RPCs is the total number of RPCs performed across every Runner.
+	RPCs int `json:"rpcs"`
+	// Duration is the wall-clock time the Harness ran for.
+	Duration time.Duration `json:"duration_ns"`
+	// Throughput is RPCs per second of wall-clock Duration.
+	Throughput float64 `json:"throughput_rps"`
+	// P50, P95 and P99 are latency percentiles across every RPC performed.
+	P50 time.Duration `json:"latency_p50_ns"`
+	P95 time.Duration `json:"latency_p95_ns"`
+	P99 time.Duration `json:"latency_p99_ns"`
+	// Errors classifies every failed RPC; see classifyError.
+	Errors map[string]int `json:"errors"`
+}
@@ -0,0 +1,153 @@
+package loadtest
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"magnapinna/api"
+)
+
+// defaultLeaseSeconds is used by NewRunner if RunnerOpts.LeaseSeconds is
+// zero.
+const defaultLeaseSeconds = 60
+
+// RunnerOpts configures one of the built-in Runners.
+type RunnerOpts struct {
+	Client api.MagnapinnaClient
+	// Identifiers is the pool of identifiers the Runner cycles through.
+	Identifiers []string
+	// LeaseSeconds is the Registration.Duration sent with each Register
+	// call. Defaults to defaultLeaseSeconds if zero.
+	LeaseSeconds int32
+	// RPS paces the Runner to roughly this many requests per second.
+	// Unpaced if zero.
+	RPS float64
+	// ThinkTime is an optional pause between a Runner's RPCs.
+	ThinkTime time.Duration
+	// Timeout bounds each individual RPC.
+	Timeout time.Duration
+	// StartDelay staggers this Runner's start, for ramp-up.
+	StartDelay time.Duration
+}
+
+// NewRunner builds one of the built-in Runners by name: "register_churn" or
+// "lease_lookup".
+func NewRunner(name string, opts RunnerOpts) (Runner, error) {
+	leaseSeconds := opts.LeaseSeconds
+	if leaseSeconds == 0 {
+		leaseSeconds = defaultLeaseSeconds
+	}
+
+	switch name {
+	case "register_churn":
+		return &RegisterChurn{
+			Client:       opts.Client,
+			Identifiers:  opts.Identifiers,
+			LeaseSeconds: leaseSeconds,
+			RPS:          opts.RPS,
+			ThinkTime:    opts.ThinkTime,
+			Timeout:      opts.Timeout,
+			StartDelay:   opts.StartDelay,
+		}, nil
+	case "lease_lookup":
+		return &LeaseLookup{
+			Client:       opts.Client,
+			Identifiers:  opts.Identifiers,
+			LeaseSeconds: leaseSeconds,
+			RPS:          opts.RPS,
+			ThinkTime:    opts.ThinkTime,
+			Timeout:      opts.Timeout,
+			StartDelay:   opts.StartDelay,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown loadtest runner %q", name)
+	}
+}
+
+// RegisterChurn is a Runner that repeats Register followed by Deregister
+// against a fixed pool of identifiers, exercising lease churn.
+type RegisterChurn struct {
+	Client       api.MagnapinnaClient
+	Identifiers  []string
+	LeaseSeconds int32
+	RPS          float64
+	ThinkTime    time.Duration
+	Timeout      time.Duration
+	StartDelay   time.Duration
+}
+
+// Run implements Runner.
+func (r *RegisterChurn) Run(ctx context.Context) (Result, error) {
+	result := Result{Errors: map[string]int{}}
+	if !sleepCtx(ctx, r.StartDelay) {
+		return result, nil
+	}
+
+	// each iteration issues two RPCs, so iterations are paced at half of RPS.
+	pace := newPacer(r.RPS / 2)
+	for i := 0; ; i++ {
+		id := r.Identifiers[i%len(r.Identifiers)]
+
+		if !timeRPC(ctx, &result, r.Timeout, func(rpcCtx context.Context) error {
+			_, err := r.Client.Register(rpcCtx, &api.Registration{Identifier: id, Duration: r.LeaseSeconds})
+			return err
+		}) {
+			return result, nil
+		}
+
+		if !timeRPC(ctx, &result, r.Timeout, func(rpcCtx context.Context) error {
+			_, err := r.Client.Deregister(rpcCtx, &api.Registration{Identifier: id, Duration: r.LeaseSeconds})
+			return err
+		}) {
+			return result, nil
+		}
+
+		if r.ThinkTime > 0 && !sleepCtx(ctx, r.ThinkTime) {
+			return result, nil
+		}
+		if !pace.wait(ctx) {
+			return result, nil
+		}
+	}
+}
+
+// LeaseLookup is a Runner that repeatedly calls CheckRegistration against a
+// fixed pool of identifiers, simulating steady-state lookup traffic once
+// leases are already registered.
+type LeaseLookup struct {
+	Client       api.MagnapinnaClient
+	Identifiers  []string
+	LeaseSeconds int32
+	RPS          float64
+	ThinkTime    time.Duration
+	Timeout      time.Duration
+	StartDelay   time.Duration
+}
+
+// Run implements Runner.
+func (r *LeaseLookup) Run(ctx context.Context) (Result, error) {
+	result := Result{Errors: map[string]int{}}
+	if !sleepCtx(ctx, r.StartDelay) {
+		return result, nil
+	}
+
+	pace := newPacer(r.RPS)
+	for i := 0; ; i++ {
+		id := r.Identifiers[i%len(r.Identifiers)]
+
+		if !timeRPC(ctx, &result, r.Timeout, func(rpcCtx context.Context) error {
+			_, err := r.Client.CheckRegistration(rpcCtx, &api.Registration{Identifier: id, Duration: r.LeaseSeconds})
+			return err
+		}) {
+			return result, nil
+		}
+
+		if r.ThinkTime > 0 && !sleepCtx(ctx, r.ThinkTime) {
+			return result, nil
+		}
+		if !pace.wait(ctx) {
+			return result, nil
+		}
+	}
+}
@@ -0,0 +1,67 @@
+// Package logger provides a single, package-level structured logger that
+// operators configure once at startup, so that code throughout magnapinna -
+// including Repository implementations that have no direct line to the
+// gRPC server's own observer - can emit correlated structured log lines
+// without each having to thread a *zap.Logger through their constructors.
+package logger
+
+import (
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Config describes how to build the global logger.
+type Config struct {
+	// Level is one of zap's level names (debug, info, warn, error), in all
+	// lower or all upper case. Defaults to "info" if empty.
+	Level string
+	// OutputPaths are passed straight through to zap.Config.OutputPaths,
+	// e.g. []string{"stdout"} or a file path. Defaults to []string{"stdout"}
+	// if empty.
+	OutputPaths []string
+}
+
+var (
+	mut    sync.RWMutex
+	global = zap.NewNop().Sugar()
+)
+
+// Setup builds the global logger from cfg. It is safe to call again later
+// (e.g. to change level at runtime); the most recent call wins.
+func Setup(cfg Config) error {
+	level := zapcore.InfoLevel
+	if cfg.Level != "" {
+		if err := level.UnmarshalText([]byte(cfg.Level)); err != nil {
+			return err
+		}
+	}
+
+	outputPaths := cfg.OutputPaths
+	if len(outputPaths) == 0 {
+		outputPaths = []string{"stdout"}
+	}
+
+	zapCfg := zap.NewProductionConfig()
+	zapCfg.Level = zap.NewAtomicLevelAt(level)
+	zapCfg.OutputPaths = outputPaths
+
+	l, err := zapCfg.Build()
+	if err != nil {
+		return err
+	}
+
+	mut.Lock()
+	global = l.Sugar()
+	mut.Unlock()
+	return nil
+}
+
+// L returns the current global logger. Before Setup is called, it is a
+// no-op logger so that code can log unconditionally without nil checks.
+func L() *zap.SugaredLogger {
+	mut.RLock()
+	defer mut.RUnlock()
+	return global
+}
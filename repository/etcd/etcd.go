@@ -0,0 +1,201 @@
+// Package etcd provides an etcd v3-backed implementation of rpc.Repository,
+// so that lease state survives server restarts and can be shared across a
+// cluster of magnapinna servers.
+package etcd
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+
+	"magnapinna/api"
+	"magnapinna/rpc"
+)
+
+// keyPrefix is prepended to every lease identifier to namespace magnapinna's
+// keys within a shared etcd cluster.
+const keyPrefix = "/magnapinna/leases/"
+
+// defaultDialTimeout is used when Config.DialTimeout is unset.
+const defaultDialTimeout = 5 * time.Second
+
+// Config configures a Repository.
+type Config struct {
+	// Endpoints is the list of etcd cluster members to dial.
+	Endpoints []string
+	// DialTimeout bounds the initial connection to the cluster. Defaults to
+	// defaultDialTimeout if zero.
+	DialTimeout time.Duration
+	// TLS configures transport security for the etcd connection. Leave nil
+	// to dial without TLS.
+	TLS *tls.Config
+	// DialOptions are passed through to the underlying etcd client in
+	// addition to any TLS configuration above.
+	DialOptions []grpc.DialOption
+}
+
+// Repository stores leases in etcd, attaching an etcd lease to each key so
+// that expired registrations are removed by etcd itself without requiring a
+// sweeper on the magnapinna side.
+type Repository struct {
+	client *clientv3.Client
+}
+
+// Repository also implements rpc.Watcher, so rpc.Server watches for leases
+// revoked out from under it (e.g. by etcd's own TTL expiry) once started.
+var _ rpc.Watcher = (*Repository)(nil)
+
+// New dials the etcd cluster described by cfg and returns a ready-to-use
+// Repository.
+func New(cfg Config) (*Repository, error) {
+	dialTimeout := cfg.DialTimeout
+	if dialTimeout == 0 {
+		dialTimeout = defaultDialTimeout
+	}
+
+	client := clientv3.Config{
+		Endpoints:   cfg.Endpoints,
+		DialTimeout: dialTimeout,
+		DialOptions: cfg.DialOptions,
+		TLS:         cfg.TLS,
+	}
+
+	cli, err := clientv3.New(client)
+	if err != nil {
+		return nil, rpc.RepositoryError{}.Wrap(err)
+	}
+	return &Repository{client: cli}, nil
+}
+
+// Close releases the underlying etcd client connection.
+func (r *Repository) Close() error {
+	return r.client.Close()
+}
+
+// StoreLease writes lease to etcd under an etcd lease whose TTL mirrors the
+// time remaining until lease.Expiration, so the key disappears on its own if
+// the server that owns it never calls DeleteLease. The write is a
+// create-or-replace transaction: whether the key already exists or not, it
+// ends up holding lease with the new etcd lease attached.
+func (r *Repository) StoreLease(ctx context.Context, lease *api.Lease) error {
+	ttl := lease.Expiration - time.Now().Unix()
+	if ttl < 1 {
+		ttl = 1
+	}
+
+	grant, err := r.client.Grant(ctx, ttl)
+	if err != nil {
+		return rpc.RepositoryError{}.Wrap(err)
+	}
+
+	value, err := proto.Marshal(lease)
+	if err != nil {
+		return rpc.RepositoryError{}.Wrap(err)
+	}
+
+	k := key(lease.Identifier)
+	put := clientv3.OpPut(k, string(value), clientv3.WithLease(grant.ID))
+	_, err = r.client.Txn(ctx).
+		If(clientv3.Compare(clientv3.CreateRevision(k), "=", 0)).
+		Then(put).
+		Else(put).
+		Commit()
+	if err != nil {
+		rpc.GlobalLogger(ctx).Errorw("failed to store lease in etcd", "identifier", lease.Identifier, "error", err)
+		return rpc.RepositoryError{}.Wrap(err)
+	}
+	return nil
+}
+
+// FetchLease looks up the lease for registration.Identifier, returning
+// rpc.ErrNoLease if no key is present (either never registered, or expired
+// and reaped by etcd).
+func (r *Repository) FetchLease(ctx context.Context, registration *api.Registration) (*api.Lease, error) {
+	resp, err := r.client.Get(ctx, key(registration.Identifier))
+	if err != nil {
+		return nil, rpc.RepositoryError{}.Wrap(err)
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, rpc.ErrNoLease
+	}
+
+	lease := &api.Lease{}
+	if err := proto.Unmarshal(resp.Kvs[0].Value, lease); err != nil {
+		return nil, rpc.RepositoryError{}.Wrap(err)
+	}
+	return lease, nil
+}
+
+// DeleteLease revokes the etcd lease backing lease.Identifier, which removes
+// the key as a side effect. A key with no etcd lease attached is deleted
+// directly. If the key is already gone, DeleteLease is a no-op.
+func (r *Repository) DeleteLease(ctx context.Context, lease *api.Lease) error {
+	resp, err := r.client.Get(ctx, key(lease.Identifier))
+	if err != nil {
+		return rpc.RepositoryError{}.Wrap(err)
+	}
+	if len(resp.Kvs) == 0 {
+		return nil
+	}
+
+	leaseID := resp.Kvs[0].Lease
+	if leaseID == 0 {
+		_, err := r.client.Delete(ctx, key(lease.Identifier))
+		if err != nil {
+			return rpc.RepositoryError{}.Wrap(err)
+		}
+		return nil
+	}
+
+	if _, err := r.client.Revoke(ctx, clientv3.LeaseID(leaseID)); err != nil {
+		return rpc.RepositoryError{}.Wrap(err)
+	}
+	return nil
+}
+
+// ListExpiredLeases returns every lease stored under keyPrefix whose
+// Expiration is at or before now. In normal operation etcd's own lease TTL
+// already reaps these keys, so this mostly guards against clock skew between
+// the server that wrote the lease and the etcd cluster's TTL accounting.
+func (r *Repository) ListExpiredLeases(ctx context.Context, now time.Time) ([]*api.Lease, error) {
+	resp, err := r.client.Get(ctx, keyPrefix, clientv3.WithPrefix())
+	if err != nil {
+		return nil, rpc.RepositoryError{}.Wrap(err)
+	}
+
+	var expired []*api.Lease
+	for _, kv := range resp.Kvs {
+		lease := &api.Lease{}
+		if err := proto.Unmarshal(kv.Value, lease); err != nil {
+			return nil, rpc.RepositoryError{}.Wrap(err)
+		}
+		if lease.Expiration <= now.Unix() {
+			expired = append(expired, lease)
+		}
+	}
+	return expired, nil
+}
+
+// Watch blocks, notifying onRevoke with the identifier of any lease key that
+// is deleted (either explicitly via DeleteLease or by etcd expiring the
+// attached lease), until ctx is cancelled.
+func (r *Repository) Watch(ctx context.Context, onRevoke func(identifier string)) {
+	watch := r.client.Watch(ctx, keyPrefix, clientv3.WithPrefix())
+	for resp := range watch {
+		for _, ev := range resp.Events {
+			if ev.Type != clientv3.EventTypeDelete {
+				continue
+			}
+			onRevoke(string(ev.Kv.Key[len(keyPrefix):]))
+		}
+	}
+}
+
+func key(identifier string) string {
+	return fmt.Sprintf("%s%s", keyPrefix, identifier)
+}
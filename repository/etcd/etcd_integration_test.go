@@ -0,0 +1,99 @@
+//go:build integration
+
+package etcd_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.etcd.io/etcd/server/v3/embed"
+
+	"magnapinna/api"
+	"magnapinna/repository/etcd"
+	"magnapinna/rpc"
+)
+
+// startEmbeddedEtcd boots a single-node etcd server in a temp directory and
+// returns its client endpoint, tearing the server down on test cleanup.
+func startEmbeddedEtcd(t *testing.T) string {
+	t.Helper()
+
+	cfg := embed.NewConfig()
+	cfg.Dir = t.TempDir()
+	cfg.LogLevel = "error"
+
+	e, err := embed.StartEtcd(cfg)
+	if err != nil {
+		t.Fatalf("failed to start embedded etcd: %s", err.Error())
+	}
+	t.Cleanup(e.Close)
+
+	select {
+	case <-e.Server.ReadyNotify():
+	case <-time.After(10 * time.Second):
+		t.Fatal("embedded etcd did not become ready in time")
+	}
+
+	return e.Clients[0].Addr().String()
+}
+
+func TestRepositoryIntegration(t *testing.T) {
+	addr := startEmbeddedEtcd(t)
+
+	repo, err := etcd.New(etcd.Config{
+		Endpoints:   []string{addr},
+		DialTimeout: 5 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("failed to construct repository: %s", err.Error())
+	}
+	defer repo.Close()
+
+	ctx := context.Background()
+	reg := &api.Registration{Identifier: "foo", Duration: 2}
+	lease := &api.Lease{Identifier: "foo", Expiration: time.Now().Unix() + 2}
+
+	if err := repo.StoreLease(ctx, lease); err != nil {
+		t.Fatalf("unexpected error storing lease: %s", err.Error())
+	}
+
+	got, err := repo.FetchLease(ctx, reg)
+	if err != nil {
+		t.Fatalf("unexpected error fetching lease: %s", err.Error())
+	}
+	if got.Identifier != lease.Identifier {
+		t.Errorf("fetched lease %+v did not match stored lease %+v", got, lease)
+	}
+
+	if err := repo.DeleteLease(ctx, lease); err != nil {
+		t.Fatalf("unexpected error deleting lease: %s", err.Error())
+	}
+	if _, err := repo.FetchLease(ctx, reg); err != rpc.ErrNoLease {
+		t.Errorf("expected ErrNoLease after delete, got %v", err)
+	}
+}
+
+func TestRepositoryIntegrationExpiry(t *testing.T) {
+	addr := startEmbeddedEtcd(t)
+
+	repo, err := etcd.New(etcd.Config{Endpoints: []string{addr}})
+	if err != nil {
+		t.Fatalf("failed to construct repository: %s", err.Error())
+	}
+	defer repo.Close()
+
+	ctx := context.Background()
+	reg := &api.Registration{Identifier: "expiring"}
+	lease := &api.Lease{Identifier: "expiring", Expiration: time.Now().Unix() + 1}
+
+	if err := repo.StoreLease(ctx, lease); err != nil {
+		t.Fatalf("unexpected error storing lease: %s", err.Error())
+	}
+
+	time.Sleep(3 * time.Second)
+
+	if _, err := repo.FetchLease(ctx, reg); err != rpc.ErrNoLease {
+		t.Errorf("expected lease to have expired via etcd TTL, got %v", err)
+	}
+}
@@ -0,0 +1,70 @@
+// Package memory provides an in-memory implementation of rpc.Repository,
+// intended for tests and local development where durability across process
+// restarts is not required.
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"magnapinna/api"
+	"magnapinna/rpc"
+)
+
+// Repository is a map-backed implementation of rpc.Repository. It is safe
+// for concurrent use.
+type Repository struct {
+	mut    sync.Mutex
+	leases map[string]*api.Lease
+}
+
+// New returns an empty, ready-to-use Repository.
+func New() *Repository {
+	return &Repository{
+		leases: make(map[string]*api.Lease),
+	}
+}
+
+// StoreLease upserts lease keyed by its Identifier.
+func (r *Repository) StoreLease(ctx context.Context, lease *api.Lease) error {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	r.leases[lease.Identifier] = lease
+	return nil
+}
+
+// FetchLease returns the lease matching registration.Identifier, or
+// rpc.ErrNoLease if none is stored.
+func (r *Repository) FetchLease(ctx context.Context, registration *api.Registration) (*api.Lease, error) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	lease, found := r.leases[registration.Identifier]
+	if !found {
+		return nil, rpc.ErrNoLease
+	}
+	return lease, nil
+}
+
+// DeleteLease removes lease from the store, if present.
+func (r *Repository) DeleteLease(ctx context.Context, lease *api.Lease) error {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	delete(r.leases, lease.Identifier)
+	return nil
+}
+
+// ListExpiredLeases returns every stored lease whose Expiration is at or
+// before now.
+func (r *Repository) ListExpiredLeases(ctx context.Context, now time.Time) ([]*api.Lease, error) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	var expired []*api.Lease
+	for _, lease := range r.leases {
+		if lease.Expiration <= now.Unix() {
+			expired = append(expired, lease)
+		}
+	}
+	return expired, nil
+}
@@ -0,0 +1,39 @@
+package memory
+
+import (
+	"context"
+	"testing"
+
+	"magnapinna/api"
+	"magnapinna/rpc"
+)
+
+func TestRepository(t *testing.T) {
+	repo := New()
+	ctx := context.Background()
+
+	reg := &api.Registration{Identifier: "foo", Duration: 1000}
+	if _, err := repo.FetchLease(ctx, reg); err != rpc.ErrNoLease {
+		t.Errorf("expected ErrNoLease before store, got %v", err)
+	}
+
+	lease := &api.Lease{Identifier: "foo", Expiration: 1000}
+	if err := repo.StoreLease(ctx, lease); err != nil {
+		t.Fatalf("unexpected error storing lease: %s", err.Error())
+	}
+
+	got, err := repo.FetchLease(ctx, reg)
+	if err != nil {
+		t.Fatalf("unexpected error fetching lease: %s", err.Error())
+	}
+	if got.Identifier != lease.Identifier || got.Expiration != lease.Expiration {
+		t.Errorf("fetched lease %+v did not match stored lease %+v", got, lease)
+	}
+
+	if err := repo.DeleteLease(ctx, lease); err != nil {
+		t.Fatalf("unexpected error deleting lease: %s", err.Error())
+	}
+	if _, err := repo.FetchLease(ctx, reg); err != rpc.ErrNoLease {
+		t.Errorf("expected ErrNoLease after delete, got %v", err)
+	}
+}
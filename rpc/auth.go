@@ -0,0 +1,171 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"magnapinna/api"
+)
+
+// Authenticator validates a bearer token extracted from a gRPC call and
+// returns the subject it identifies.
+type Authenticator interface {
+	Authenticate(ctx context.Context, token string) (subject string, err error)
+}
+
+// JWTAuthenticator validates tokens against a JWKS endpoint, as issued by
+// the oauth2.Token credentials the Client attaches via oauth.NewOauthAccess.
+type JWTAuthenticator struct {
+	keyset jwk.Set
+}
+
+// NewJWTAuthenticator fetches and caches the JWKS served at jwksURL.
+func NewJWTAuthenticator(ctx context.Context, jwksURL string) (*JWTAuthenticator, error) {
+	set, err := jwk.Fetch(ctx, jwksURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS from %s: %w", jwksURL, err)
+	}
+	return &JWTAuthenticator{keyset: set}, nil
+}
+
+// Authenticate implements Authenticator.
+func (a *JWTAuthenticator) Authenticate(ctx context.Context, token string) (string, error) {
+	parsed, err := jwt.Parse([]byte(token), jwt.WithKeySet(a.keyset), jwt.WithValidate(true))
+	if err != nil {
+		return "", fmt.Errorf("invalid token: %w", err)
+	}
+	return parsed.Subject(), nil
+}
+
+// authConfig controls how incoming gRPC calls are authenticated.
+type authConfig struct {
+	authenticator Authenticator
+	// unauthenticated lists full gRPC method names (e.g.
+	// "/Magnapinna/CheckRegistration") that skip authentication entirely.
+	unauthenticated map[string]bool
+	// identified lists full method names whose request message is an
+	// *api.Registration whose Identifier must match the token subject.
+	identified map[string]bool
+}
+
+// newAuthConfig builds an authConfig allowing the given methods to bypass
+// authentication.
+func newAuthConfig(authenticator Authenticator, unauthenticated []string) *authConfig {
+	skip := make(map[string]bool, len(unauthenticated))
+	for _, m := range unauthenticated {
+		skip[m] = true
+	}
+	return &authConfig{
+		authenticator:   authenticator,
+		unauthenticated: skip,
+		identified: map[string]bool{
+			"/Magnapinna/Register":     true,
+			"/Magnapinna/Deregister":   true,
+			"/Magnapinna/JoinCluster":  true,
+			"/Magnapinna/StartSession": true,
+		},
+	}
+}
+
+// authenticate pulls the bearer token out of ctx, validates it, and - for
+// identified methods - enforces that the token subject matches identifier.
+func (a *authConfig) authenticate(ctx context.Context, method string, identifier string) (context.Context, error) {
+	if a.unauthenticated[method] {
+		return ctx, nil
+	}
+
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ctx, status.Error(codes.Unauthenticated, "missing metadata")
+	}
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return ctx, status.Error(codes.Unauthenticated, "missing authorization header")
+	}
+
+	token := strings.TrimPrefix(values[0], "Bearer ")
+	subject, err := a.authenticator.Authenticate(ctx, token)
+	if err != nil {
+		return ctx, status.Errorf(codes.Unauthenticated, "invalid token: %s", err.Error())
+	}
+
+	if a.identified[method] && identifier != "" && subject != identifier {
+		return ctx, status.Errorf(codes.Unauthenticated, "token subject %q does not match identifier %q", subject, identifier)
+	}
+
+	return context.WithValue(ctx, subjectKey{}, subject), nil
+}
+
+type subjectKey struct{}
+
+// SubjectFromContext returns the authenticated subject attached by the auth
+// interceptors, if any.
+func SubjectFromContext(ctx context.Context) (string, bool) {
+	subject, ok := ctx.Value(subjectKey{}).(string)
+	return subject, ok
+}
+
+// checkSubjectMatch enforces that, if ctx carries an authenticated subject,
+// it matches identifier. StreamAuthInterceptor can't do this itself - the
+// identifier lives in the stream's first message, not its metadata - so
+// JoinCluster and StartSession call this once they've received it. If no
+// subject is attached to ctx (authentication is disabled, or the method is
+// listed in UnauthenticatedMethods), this is a no-op.
+func checkSubjectMatch(ctx context.Context, identifier string) error {
+	subject, ok := SubjectFromContext(ctx)
+	if !ok || subject == identifier {
+		return nil
+	}
+	return status.Errorf(codes.Unauthenticated, "token subject %q does not match identifier %q", subject, identifier)
+}
+
+// UnaryAuthInterceptor enforces authentication on unary calls, chained
+// alongside UnaryObserver.
+func (o *observer) UnaryAuthInterceptor(auth *authConfig) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		identifier := ""
+		if rs, ok := req.(*api.Registration); ok {
+			identifier = rs.Identifier
+		}
+
+		ctx, err := auth.authenticate(ctx, info.FullMethod, identifier)
+		if err != nil {
+			o.ObserveAuthFailure(info.FullMethod)
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamAuthInterceptor enforces authentication on streaming calls, chained
+// alongside StreamObserver.
+func (o *observer) StreamAuthInterceptor(auth *authConfig) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := auth.authenticate(stream.Context(), info.FullMethod, "")
+		if err != nil {
+			o.ObserveAuthFailure(info.FullMethod)
+			return err
+		}
+		return handler(srv, &authenticatedStream{ServerStream: stream, ctx: ctx})
+	}
+}
+
+// authenticatedStream overrides Context() to carry the subject attached by
+// authenticate, since grpc.ServerStream does not expose a way to replace the
+// context in place.
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
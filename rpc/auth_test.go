@@ -0,0 +1,167 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"magnapinna/api"
+)
+
+// fakeAuthenticator implements Authenticator, returning a fixed subject or
+// error regardless of the token presented.
+type fakeAuthenticator struct {
+	subject string
+	err     error
+}
+
+func (f *fakeAuthenticator) Authenticate(ctx context.Context, token string) (string, error) {
+	if f.err != nil {
+		return "", f.err
+	}
+	return f.subject, nil
+}
+
+func ctxWithBearerToken(token string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+}
+
+func TestAuthenticateSkipsUnauthenticatedMethods(t *testing.T) {
+	auth := newAuthConfig(&fakeAuthenticator{err: errors.New("should not be called")}, []string{"/Magnapinna/CheckRegistration"})
+
+	ctx, err := auth.authenticate(context.Background(), "/Magnapinna/CheckRegistration", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if _, ok := SubjectFromContext(ctx); ok {
+		t.Error("expected no subject attached for a skipped method")
+	}
+}
+
+func TestAuthenticateMissingMetadata(t *testing.T) {
+	auth := newAuthConfig(&fakeAuthenticator{subject: "foo"}, nil)
+
+	_, err := auth.authenticate(context.Background(), "/Magnapinna/Register", "foo")
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated, got %v", err)
+	}
+}
+
+func TestAuthenticateInvalidToken(t *testing.T) {
+	auth := newAuthConfig(&fakeAuthenticator{err: errors.New("bad token")}, nil)
+
+	_, err := auth.authenticate(ctxWithBearerToken("anything"), "/Magnapinna/Register", "foo")
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated, got %v", err)
+	}
+}
+
+func TestAuthenticateIdentifierMatch(t *testing.T) {
+	auth := newAuthConfig(&fakeAuthenticator{subject: "foo"}, nil)
+
+	ctx, err := auth.authenticate(ctxWithBearerToken("anything"), "/Magnapinna/Register", "foo")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if subject, ok := SubjectFromContext(ctx); !ok || subject != "foo" {
+		t.Errorf("expected subject %q in context, got %q (ok=%v)", "foo", subject, ok)
+	}
+}
+
+func TestAuthenticateIdentifierMismatch(t *testing.T) {
+	auth := newAuthConfig(&fakeAuthenticator{subject: "foo"}, nil)
+
+	_, err := auth.authenticate(ctxWithBearerToken("anything"), "/Magnapinna/Register", "bar")
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated for a mismatched identifier, got %v", err)
+	}
+}
+
+func TestUnaryAuthInterceptorEnforcesRegistrationIdentifier(t *testing.T) {
+	auth := newAuthConfig(&fakeAuthenticator{subject: "foo"}, nil)
+	obs := NewObserver(ioutil.Discard)
+	interceptor := obs.UnaryAuthInterceptor(auth)
+	info := &grpc.UnaryServerInfo{FullMethod: "/Magnapinna/Register"}
+
+	var handlerCalled bool
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		handlerCalled = true
+		return nil, nil
+	}
+
+	if _, err := interceptor(ctxWithBearerToken("anything"), &api.Registration{Identifier: "foo"}, info, handler); err != nil {
+		t.Fatalf("unexpected error for a matching identifier: %s", err.Error())
+	}
+	if !handlerCalled {
+		t.Error("expected handler to be called for a matching identifier")
+	}
+
+	handlerCalled = false
+	_, err := interceptor(ctxWithBearerToken("anything"), &api.Registration{Identifier: "bar"}, info, handler)
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated for a mismatched identifier, got %v", err)
+	}
+	if handlerCalled {
+		t.Error("expected handler not to be called for a mismatched identifier")
+	}
+}
+
+func TestStreamAuthInterceptorAttachesSubjectWithoutEnforcingIdentity(t *testing.T) {
+	// The streaming identifier isn't known until the first Recv() inside the
+	// handler, so the interceptor itself can only authenticate the token and
+	// attach the subject; checkSubjectMatch (exercised below) does the
+	// actual per-identifier enforcement once the handler has one.
+	auth := newAuthConfig(&fakeAuthenticator{subject: "foo"}, nil)
+	obs := NewObserver(ioutil.Discard)
+	interceptor := obs.StreamAuthInterceptor(auth)
+	info := &grpc.StreamServerInfo{FullMethod: "/Magnapinna/JoinCluster"}
+
+	var gotCtx context.Context
+	handler := func(srv interface{}, stream grpc.ServerStream) error {
+		gotCtx = stream.Context()
+		return nil
+	}
+
+	stream := &fakeServerStream{ctx: ctxWithBearerToken("anything")}
+	if err := interceptor(nil, stream, info, handler); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if subject, ok := SubjectFromContext(gotCtx); !ok || subject != "foo" {
+		t.Errorf("expected subject %q attached to the handler's stream context, got %q (ok=%v)", "foo", subject, ok)
+	}
+}
+
+func TestCheckSubjectMatch(t *testing.T) {
+	matching := context.WithValue(context.Background(), subjectKey{}, "foo")
+	if err := checkSubjectMatch(matching, "foo"); err != nil {
+		t.Errorf("unexpected error for a matching subject: %s", err.Error())
+	}
+
+	mismatched := context.WithValue(context.Background(), subjectKey{}, "foo")
+	if err := checkSubjectMatch(mismatched, "bar"); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated for a mismatched subject, got %v", err)
+	}
+
+	if err := checkSubjectMatch(context.Background(), "anything"); err != nil {
+		t.Errorf("expected no error when ctx carries no subject (auth disabled), got %s", err.Error())
+	}
+}
+
+// fakeServerStream is a minimal grpc.ServerStream for exercising interceptors
+// without a real transport.
+type fakeServerStream struct {
+	ctx context.Context
+}
+
+func (f *fakeServerStream) SetHeader(metadata.MD) error  { return nil }
+func (f *fakeServerStream) SendHeader(metadata.MD) error { return nil }
+func (f *fakeServerStream) SetTrailer(metadata.MD)       {}
+func (f *fakeServerStream) Context() context.Context     { return f.ctx }
+func (f *fakeServerStream) SendMsg(m interface{}) error  { return nil }
+func (f *fakeServerStream) RecvMsg(m interface{}) error  { return nil }
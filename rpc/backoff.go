@@ -0,0 +1,63 @@
+package rpc
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Backoff computes the delay to wait before the next retry of an operation.
+// Implementations are expected to be safe for concurrent use only insofar as
+// a single Client uses a single Backoff from a single goroutine at a time.
+type Backoff interface {
+	// Next returns the delay to wait before retry number retries (0-indexed).
+	Next(retries int) time.Duration
+	// Reset signals that an operation succeeded, so implementations that
+	// track state (e.g. a moving window) can clear it.
+	Reset()
+}
+
+// ExponentialBackoff implements Backoff with the same shape as gRPC's
+// default connection backoff: delay is multiplied by Factor each retry,
+// capped at MaxDelay, with +/- Jitter randomization applied on top.
+type ExponentialBackoff struct {
+	// BaseDelay is the delay used for the first retry.
+	BaseDelay time.Duration
+	// Factor is the multiplier applied to the delay on each subsequent retry.
+	Factor float64
+	// Jitter is the fractional amount of randomization applied to the delay,
+	// e.g. 0.2 means +/- 20%.
+	Jitter float64
+	// MaxDelay caps the computed delay before jitter is applied.
+	MaxDelay time.Duration
+}
+
+// DefaultBackoff mirrors grpc's default connection backoff configuration.
+func DefaultBackoff() *ExponentialBackoff {
+	return &ExponentialBackoff{
+		BaseDelay: time.Second,
+		Factor:    1.6,
+		Jitter:    0.2,
+		MaxDelay:  120 * time.Second,
+	}
+}
+
+// Next implements Backoff.
+func (b *ExponentialBackoff) Next(retries int) time.Duration {
+	delay := float64(b.BaseDelay)
+	for i := 0; i < retries; i++ {
+		delay *= b.Factor
+		if delay > float64(b.MaxDelay) {
+			delay = float64(b.MaxDelay)
+			break
+		}
+	}
+
+	delay *= 1 + b.Jitter*(2*rand.Float64()-1)
+	if delay < 0 {
+		delay = 0
+	}
+	return time.Duration(delay)
+}
+
+// Reset is a no-op: ExponentialBackoff is stateless between calls to Next.
+func (b *ExponentialBackoff) Reset() {}
@@ -0,0 +1,117 @@
+package rpc
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDefaultBackoff(t *testing.T) {
+	b := DefaultBackoff()
+
+	if b.BaseDelay != time.Second {
+		t.Errorf("expected BaseDelay of %s, got %s", time.Second, b.BaseDelay)
+	}
+	if b.Factor != 1.6 {
+		t.Errorf("expected Factor of 1.6, got %f", b.Factor)
+	}
+	if b.Jitter != 0.2 {
+		t.Errorf("expected Jitter of 0.2, got %f", b.Jitter)
+	}
+	if b.MaxDelay != 120*time.Second {
+		t.Errorf("expected MaxDelay of %s, got %s", 120*time.Second, b.MaxDelay)
+	}
+}
+
+func TestExponentialBackoffNextWithoutJitter(t *testing.T) {
+	cases := []struct {
+		name    string
+		retries int
+		want    time.Duration
+	}{
+		{name: "first retry uses BaseDelay", retries: 0, want: time.Second},
+		{name: "second retry scales by Factor", retries: 1, want: time.Duration(1.6 * float64(time.Second))},
+		{name: "third retry scales by Factor twice", retries: 2, want: time.Duration(1.6 * 1.6 * float64(time.Second))},
+		{name: "clamps at MaxDelay", retries: 20, want: 10 * time.Second},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			b := &ExponentialBackoff{
+				BaseDelay: time.Second,
+				Factor:    1.6,
+				MaxDelay:  10 * time.Second,
+			}
+
+			if got := b.Next(c.retries); got != c.want {
+				t.Errorf("expected %s, got %s", c.want, got)
+			}
+		})
+	}
+}
+
+func TestExponentialBackoffNextIsMonotonicallyIncreasing(t *testing.T) {
+	b := &ExponentialBackoff{
+		BaseDelay: time.Millisecond,
+		Factor:    2,
+		MaxDelay:  time.Hour,
+	}
+
+	prev := time.Duration(0)
+	for retries := 0; retries < 10; retries++ {
+		got := b.Next(retries)
+		if got <= prev {
+			t.Fatalf("retry %d: expected delay to increase, got %s after %s", retries, got, prev)
+		}
+		prev = got
+	}
+}
+
+func TestExponentialBackoffNextAppliesJitterWithinBounds(t *testing.T) {
+	b := &ExponentialBackoff{
+		BaseDelay: time.Second,
+		Factor:    1.6,
+		Jitter:    0.2,
+		MaxDelay:  time.Minute,
+	}
+
+	lower := time.Duration(0.8 * float64(time.Second))
+	upper := time.Duration(1.2 * float64(time.Second))
+
+	for i := 0; i < 50; i++ {
+		got := b.Next(0)
+		if got < lower || got > upper {
+			t.Fatalf("expected delay within [%s, %s], got %s", lower, upper, got)
+		}
+	}
+}
+
+func TestExponentialBackoffNextNeverNegative(t *testing.T) {
+	b := &ExponentialBackoff{
+		BaseDelay: time.Millisecond,
+		Factor:    1,
+		Jitter:    1.5,
+		MaxDelay:  time.Second,
+	}
+
+	for i := 0; i < 50; i++ {
+		if got := b.Next(0); got < 0 {
+			t.Fatalf("expected a non-negative delay, got %s", got)
+		}
+	}
+}
+
+func TestExponentialBackoffResetIsNoop(t *testing.T) {
+	b := &ExponentialBackoff{
+		BaseDelay: time.Second,
+		Factor:    1.6,
+		MaxDelay:  10 * time.Second,
+	}
+
+	before := b.Next(3)
+	b.Reset()
+	after := b.Next(3)
+
+	if before != after {
+		t.Errorf("expected Reset to be a no-op, got %s before and %s after", before, after)
+	}
+}
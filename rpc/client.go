@@ -2,6 +2,8 @@ package rpc
 
 import (
 	"context"
+	"fmt"
+	"io/ioutil"
 	"magnapinna/api"
 	"time"
 
@@ -18,6 +20,7 @@ type Client struct {
 	lease    time.Duration
 	timeout  time.Duration
 	ctx      context.Context
+	backoff  Backoff
 }
 
 type ClientOpts struct {
@@ -26,22 +29,62 @@ type ClientOpts struct {
 	Timeout time.Duration
 	Token   oauth2.Token
 	Context context.Context
+	// Backoff controls the delay between dial/reconnect attempts. If nil,
+	// DefaultBackoff is used; pass &NoBackoff{} to retry immediately.
+	Backoff Backoff
 }
 
+// NoBackoff is a Backoff that never delays, for callers that want retries
+// with no pacing between them.
+type NoBackoff struct{}
+
+// Next always returns 0.
+func (NoBackoff) Next(retries int) time.Duration { return 0 }
+
+// Reset is a no-op.
+func (NoBackoff) Reset() {}
+
 func NewClient(opts ClientOpts) (*Client, error) {
-	conn, err := grpc.Dial(opts.Addr, grpc.WithPerRPCCredentials(oauth.NewOauthAccess(&opts.Token)))
+	backoff := opts.Backoff
+	if backoff == nil {
+		backoff = DefaultBackoff()
+	}
+
+	conn, err := dialWithBackoff(opts.Context, opts.Addr, backoff,
+		grpc.WithPerRPCCredentials(oauth.NewOauthAccess(&opts.Token)))
 	if err != nil {
 		return nil, err
 	}
 
 	return &Client{
-		grpc:    api.NewMagnapinnaClient(conn),
-		id:      opts.ID,
-		ctx:     opts.Context,
-		timeout: opts.Timeout,
+		grpc:     api.NewMagnapinnaClient(conn),
+		id:       opts.ID,
+		ctx:      opts.Context,
+		timeout:  opts.Timeout,
+		backoff:  backoff,
+		observer: NewObserver(ioutil.Discard),
 	}, nil
 }
 
+// dialWithBackoff retries grpc.DialContext, pacing attempts with backoff,
+// until it succeeds or ctx is cancelled.
+func dialWithBackoff(ctx context.Context, addr string, backoff Backoff, dialOpts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	var lastErr error
+	for retries := 0; ; retries++ {
+		conn, err := grpc.DialContext(ctx, addr, dialOpts...)
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("dial %s: %w (last error: %s)", addr, ctx.Err(), lastErr.Error())
+		case <-time.After(backoff.Next(retries)):
+		}
+	}
+}
+
 // TODO double-check that GRPC will correctly handle the timeout
 func (c *Client) Register() (*api.Lease, error) {
 	ctx, cancel := context.WithTimeout(c.ctx, c.timeout)
@@ -71,8 +114,93 @@ func (c *Client) Deregister() (*api.Lease, error) {
 	})
 }
 
-func (c *Client) JoinCluster(ctx context.Context, opts ...grpc.CallOption) (api.Magnapinna_JoinClusterClient, error) {
-	return nil, nil
+// CommandHandler executes a Command received over a JoinCluster stream and
+// returns the Output to send back to the server.
+type CommandHandler func(*api.Command) (*api.Output, error)
+
+// RunJoinCluster opens a JoinCluster stream, identifies itself, and pumps
+// commands from the server to handle until the stream closes (e.g. the
+// server sets the "closed" trailer, or a transport error occurs), at which
+// point it reconnects using c.backoff until ctx is cancelled.
+func (c *Client) RunJoinCluster(ctx context.Context, handle CommandHandler) error {
+	retries := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if _, err := c.Register(); err != nil {
+			c.observer.ObserveGRPCCall(ctx, "join_cluster_register", err)
+			if !sleepBackoff(ctx, c.backoff, &retries) {
+				return ctx.Err()
+			}
+			continue
+		}
+
+		stream, err := c.grpc.JoinCluster(ctx)
+		if err != nil {
+			c.observer.ObserveGRPCCall(ctx, "join_cluster_dial", err)
+			if !sleepBackoff(ctx, c.backoff, &retries) {
+				return ctx.Err()
+			}
+			continue
+		}
+
+		if err := stream.Send(&api.Output{Identifier: c.id}); err != nil {
+			c.observer.ObserveGRPCCall(ctx, "join_cluster_init_send", err)
+			if !sleepBackoff(ctx, c.backoff, &retries) {
+				return ctx.Err()
+			}
+			continue
+		}
+
+		// Connected and re-registered successfully; forget prior failures.
+		c.backoff.Reset()
+		retries = 0
+
+		if err := pumpCommands(stream, handle); err != nil {
+			c.observer.ObserveGRPCCall(ctx, "join_cluster_stream", err)
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !sleepBackoff(ctx, c.backoff, &retries) {
+			return ctx.Err()
+		}
+	}
+}
+
+// pumpCommands reads Commands from stream and writes back the handler's
+// Output until the stream errors or closes.
+func pumpCommands(stream api.Magnapinna_JoinClusterClient, handle CommandHandler) error {
+	for {
+		cmd, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+
+		output, err := handle(cmd)
+		if err != nil {
+			output = &api.Output{Identifier: cmd.Identifier}
+		}
+		if err := stream.Send(output); err != nil {
+			return err
+		}
+	}
+}
+
+// sleepBackoff waits for backoff's next delay or ctx cancellation, returning
+// false if ctx was cancelled first.
+func sleepBackoff(ctx context.Context, backoff Backoff, retries *int) bool {
+	delay := backoff.Next(*retries)
+	*retries++
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(delay):
+		return true
+	}
 }
 
 func (c *Client) StartSession(ctx context.Context, opts ...grpc.CallOption) (api.Magnapinna_StartSessionClient, error) {
@@ -0,0 +1,168 @@
+package rpc
+
+import (
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"magnapinna/api"
+)
+
+// defaultHealthWindow is how long a streamEntry is considered unhealthy
+// after its last send/recv error, if ConnCache was not given an explicit
+// window.
+const defaultHealthWindow = 10 * time.Second
+
+// maxDispatchAttempts bounds how many healthy streams StartSession will try
+// before giving up on a single command.
+const maxDispatchAttempts = 3
+
+// streamEntry tracks one JoinCluster stream for an identifier, along with
+// enough health state for ConnCache's picker to route around it once it
+// starts failing.
+type streamEntry struct {
+	stream api.Magnapinna_JoinClusterServer
+
+	mut      sync.Mutex
+	lastErr  time.Time
+	hasErred bool
+}
+
+func (e *streamEntry) send(cmd *api.Command) error {
+	err := e.stream.Send(cmd)
+	e.recordResult(err)
+	return err
+}
+
+func (e *streamEntry) recv() (*api.Output, error) {
+	output, err := e.stream.Recv()
+	e.recordResult(err)
+	return output, err
+}
+
+func (e *streamEntry) recordResult(err error) {
+	e.mut.Lock()
+	defer e.mut.Unlock()
+	if err != nil {
+		e.hasErred = true
+		e.lastErr = time.Now()
+		return
+	}
+	e.hasErred = false
+}
+
+// healthy reports whether e's stream is still open and hasn't errored
+// within window.
+func (e *streamEntry) healthy(window time.Duration) bool {
+	if e.stream.Context().Err() != nil {
+		return false
+	}
+	e.mut.Lock()
+	defer e.mut.Unlock()
+	return !e.hasErred || time.Since(e.lastErr) > window
+}
+
+// ConnCache holds the JoinCluster streams currently registered per
+// identifier. Unlike a single-stream cache, it allows multiple redundant
+// streams per identifier and routes StartSession traffic to whichever one
+// is currently healthy.
+type ConnCache struct {
+	mut    sync.Mutex
+	active map[string][]*streamEntry
+	cursor map[string]int
+	window time.Duration
+}
+
+// NewConnCache returns an empty ConnCache. window controls how long a
+// stream is treated as unhealthy after its most recent error; pass 0 to use
+// defaultHealthWindow.
+func NewConnCache(window time.Duration) ConnCache {
+	if window == 0 {
+		window = defaultHealthWindow
+	}
+	return ConnCache{
+		active: make(map[string][]*streamEntry),
+		cursor: make(map[string]int),
+		window: window,
+	}
+}
+
+// addClient registers join as an additional stream for id, returning the
+// handle used to remove it again once the stream closes.
+func (c *ConnCache) addClient(id string, join api.Magnapinna_JoinClusterServer) *streamEntry {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	entry := &streamEntry{stream: join}
+	c.active[id] = append(c.active[id], entry)
+	return entry
+}
+
+// removeEntry evicts entry from id's stream list, e.g. once its stream
+// closes or errors irrecoverably.
+func (c *ConnCache) removeEntry(id string, entry *streamEntry) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	entries := c.active[id]
+	for i, e := range entries {
+		if e == entry {
+			c.active[id] = append(entries[:i], entries[i+1:]...)
+			break
+		}
+	}
+	if len(c.active[id]) == 0 {
+		delete(c.active, id)
+		delete(c.cursor, id)
+	}
+}
+
+// pick selects the next healthy stream for id, round-robin among those
+// whose context is still live and whose last error is outside the health
+// window. It returns codes.Unavailable if id has no entries, or none are
+// currently healthy.
+func (c *ConnCache) pick(id string) (*streamEntry, error) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	entries := c.active[id]
+	if len(entries) == 0 {
+		return nil, status.Error(codes.Unavailable, "no clients registered for identifier")
+	}
+
+	start := c.cursor[id]
+	for i := 0; i < len(entries); i++ {
+		idx := (start + i) % len(entries)
+		if entries[idx].healthy(c.window) {
+			c.cursor[id] = (idx + 1) % len(entries)
+			return entries[idx], nil
+		}
+	}
+	return nil, status.Error(codes.Unavailable, "no healthy clients for identifier")
+}
+
+// healthy reports whether id has at least one healthy stream registered,
+// for use by healthServer's grpc_health_v1 Check and Watch.
+func (c *ConnCache) healthy(id string) bool {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	for _, e := range c.active[id] {
+		if e.healthy(c.window) {
+			return true
+		}
+	}
+	return false
+}
+
+// getClient returns any currently registered stream for id, bypassing the
+// health picker. Kept for callers (and tests) that only care whether an
+// identifier has joined at all.
+func (c *ConnCache) getClient(id string) (api.Magnapinna_JoinClusterServer, error) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	entries := c.active[id]
+	if len(entries) == 0 {
+		return nil, status.Error(codes.Unavailable, "no action client with ID "+id)
+	}
+	return entries[0].stream, nil
+}
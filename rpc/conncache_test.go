@@ -0,0 +1,135 @@
+package rpc
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"magnapinna/api"
+)
+
+// fakeJoinClusterStream is a minimal api.Magnapinna_JoinClusterServer for
+// exercising ConnCache/streamEntry/dispatch without a real gRPC transport.
+type fakeJoinClusterStream struct {
+	ctx     context.Context
+	sendErr error
+	recvErr error
+}
+
+func (f *fakeJoinClusterStream) Send(cmd *api.Command) error  { return f.sendErr }
+func (f *fakeJoinClusterStream) Recv() (*api.Output, error)   { return &api.Output{}, f.recvErr }
+func (f *fakeJoinClusterStream) SetHeader(metadata.MD) error  { return nil }
+func (f *fakeJoinClusterStream) SendHeader(metadata.MD) error { return nil }
+func (f *fakeJoinClusterStream) SetTrailer(metadata.MD)       {}
+func (f *fakeJoinClusterStream) Context() context.Context     { return f.ctx }
+func (f *fakeJoinClusterStream) SendMsg(m interface{}) error  { return nil }
+func (f *fakeJoinClusterStream) RecvMsg(m interface{}) error  { return nil }
+
+func newFakeStream() *fakeJoinClusterStream {
+	return &fakeJoinClusterStream{ctx: context.Background()}
+}
+
+func TestConnCacheRoundRobin(t *testing.T) {
+	cache := NewConnCache(time.Minute)
+	e1 := cache.addClient("foo", newFakeStream())
+	e2 := cache.addClient("foo", newFakeStream())
+
+	first, err := cache.pick("foo")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if first != e1 {
+		t.Error("expected first pick to return the first-added entry")
+	}
+
+	second, err := cache.pick("foo")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if second != e2 {
+		t.Error("expected second pick to round-robin to the second entry")
+	}
+
+	third, err := cache.pick("foo")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if third != e1 {
+		t.Error("expected third pick to wrap back around to the first entry")
+	}
+}
+
+func TestConnCachePicksAroundUnhealthyEntries(t *testing.T) {
+	cache := NewConnCache(time.Minute)
+	unhealthy := cache.addClient("foo", newFakeStream())
+	unhealthy.recordResult(status.Error(codes.Unavailable, "boom"))
+	healthy := cache.addClient("foo", newFakeStream())
+
+	picked, err := cache.pick("foo")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if picked != healthy {
+		t.Error("expected pick to skip the unhealthy entry")
+	}
+}
+
+func TestConnCachePickNoEntries(t *testing.T) {
+	cache := NewConnCache(time.Minute)
+
+	_, err := cache.pick("missing")
+	if status.Code(err) != codes.Unavailable {
+		t.Fatalf("expected Unavailable, got %v", err)
+	}
+}
+
+func TestConnCachePickExhausted(t *testing.T) {
+	cache := NewConnCache(time.Minute)
+	entry := cache.addClient("foo", newFakeStream())
+	entry.recordResult(status.Error(codes.Unavailable, "boom"))
+
+	_, err := cache.pick("foo")
+	if status.Code(err) != codes.Unavailable {
+		t.Fatalf("expected Unavailable when every entry is unhealthy, got %v", err)
+	}
+}
+
+func TestConnCacheAddAndRemoveEntry(t *testing.T) {
+	cache := NewConnCache(time.Minute)
+	entry := cache.addClient("foo", newFakeStream())
+
+	if _, err := cache.pick("foo"); err != nil {
+		t.Fatalf("unexpected error after add: %s", err.Error())
+	}
+
+	cache.removeEntry("foo", entry)
+	if _, err := cache.pick("foo"); status.Code(err) != codes.Unavailable {
+		t.Fatalf("expected Unavailable after eviction, got %v", err)
+	}
+	if _, ok := cache.active["foo"]; ok {
+		t.Error("expected the identifier to be pruned from active once its last entry is removed")
+	}
+}
+
+func TestDispatchReturnsUnavailableWhenStreamsExhausted(t *testing.T) {
+	cache := NewConnCache(time.Minute)
+	cache.addClient("foo", &fakeJoinClusterStream{
+		ctx:     context.Background(),
+		sendErr: status.Error(codes.Unavailable, "boom"),
+	})
+
+	srv := &Server{
+		conns:    cache,
+		observer: NewObserver(ioutil.Discard),
+	}
+
+	_, err := srv.dispatch(context.Background(), "foo", &api.Command{})
+	if status.Code(err) != codes.Unavailable {
+		t.Fatalf("expected Unavailable once every attempt fails, got %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+package rpc
+
+import (
+	"context"
+	"time"
+)
+
+// Clock abstracts time.Now so controllers can be driven deterministically in
+// tests, without sleeping for real intervals.
+type Clock interface {
+	Now() time.Time
+}
+
+// realClock implements Clock using the wall clock.
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+// Controller is a unit of periodic background work run by a Manager
+// alongside the gRPC listener, e.g. reaping expired leases or reporting
+// metrics.
+type Controller interface {
+	// Name identifies the controller in observer events.
+	Name() string
+	// Interval is how often Manager calls Run.
+	Interval() time.Duration
+	// Run performs one iteration of the controller's work.
+	Run(ctx context.Context) error
+}
+
+// Manager runs a set of Controllers, each on its own ticker, stopping all of
+// them together when its context is cancelled.
+type Manager struct {
+	controllers []Controller
+	observer    Observer
+}
+
+// NewManager returns a Manager ready to run controllers. Errors returned by
+// a controller's Run are reported through observer; pass NopObserver{} to
+// discard them.
+func NewManager(observer Observer, controllers ...Controller) *Manager {
+	return &Manager{controllers: controllers, observer: observer}
+}
+
+// Run starts every registered controller on its own goroutine and blocks
+// until ctx is cancelled.
+func (m *Manager) Run(ctx context.Context) {
+	done := make(chan struct{})
+	for _, c := range m.controllers {
+		go m.runController(ctx, c, done)
+	}
+	<-ctx.Done()
+	for range m.controllers {
+		<-done
+	}
+}
+
+func (m *Manager) runController(ctx context.Context, c Controller, done chan<- struct{}) {
+	ticker := time.NewTicker(c.Interval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			done <- struct{}{}
+			return
+		case <-ticker.C:
+			if err := c.Run(ctx); err != nil {
+				m.observer.ControllerError(ctx, c.Name(), err)
+			}
+		}
+	}
+}
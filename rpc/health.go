@@ -0,0 +1,70 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// healthWatchPollInterval controls how often Watch re-checks health between
+// pushing updates to the caller.
+const healthWatchPollInterval = time.Second
+
+// healthServer implements grpc_health_v1.HealthServer, reporting per-identifier
+// readiness based on ConnCache: a service name (HealthCheckRequest.Service)
+// is treated as a client identifier, and is SERVING iff ConnCache has at
+// least one healthy JoinCluster stream registered for it. An empty service
+// name reports the overall server as SERVING.
+type healthServer struct {
+	grpc_health_v1.UnimplementedHealthServer
+	conns *ConnCache
+}
+
+func newHealthServer(conns *ConnCache) *healthServer {
+	return &healthServer{conns: conns}
+}
+
+// Check implements grpc_health_v1.HealthServer.
+func (h *healthServer) Check(ctx context.Context, req *grpc_health_v1.HealthCheckRequest) (*grpc_health_v1.HealthCheckResponse, error) {
+	if req.Service == "" {
+		return &grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_SERVING}, nil
+	}
+
+	if h.conns.healthy(req.Service) {
+		return &grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_SERVING}, nil
+	}
+	return &grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_NOT_SERVING}, nil
+}
+
+// Watch implements grpc_health_v1.HealthServer, polling the requested
+// identifier's health on each change and pushing updates until the stream's
+// context is cancelled.
+func (h *healthServer) Watch(req *grpc_health_v1.HealthCheckRequest, stream grpc_health_v1.Health_WatchServer) error {
+	var last grpc_health_v1.HealthCheckResponse_ServingStatus = -1
+	ctx := stream.Context()
+	ticker := time.NewTicker(healthWatchPollInterval)
+	defer ticker.Stop()
+
+	for {
+		resp, err := h.Check(ctx, req)
+		if err != nil {
+			return err
+		}
+		if resp.Status != last {
+			if err := stream.Send(resp); err != nil {
+				return fmt.Errorf("failed to send health update: %w", err)
+			}
+			last = resp.Status
+		}
+
+		select {
+		case <-ctx.Done():
+			return status.Error(codes.Canceled, "health watch cancelled")
+		case <-ticker.C:
+		}
+	}
+}
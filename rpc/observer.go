@@ -2,24 +2,62 @@ package rpc
 
 import (
 	"context"
-	"fmt"
 	"io"
 	"io/ioutil"
-	"magnapinna/api"
 	"os"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/prometheus/client_golang/prometheus"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"magnapinna/api"
 )
 
-// TODO convert observability functions to gRPC interceptors
+// requestIDKey is the context key under which the per-request correlation
+// id is stored, once extracted/generated by the interceptors below.
+type requestIDKey struct{}
+
+const requestIDMetadataKey = "x-request-id"
+
+// CorrelationID returns the correlation id attached to ctx by UnaryObserver
+// or StreamObserver, if any.
+func CorrelationID(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIDKey{}).(string)
+	return id, ok
+}
+
+// withCorrelationID reads x-request-id out of ctx's incoming metadata,
+// generating one if absent, and returns a ctx that carries it both as a
+// value (for CorrelationID) and as outgoing metadata, so that any gRPC
+// calls made with the returned ctx propagate it downstream.
+func withCorrelationID(ctx context.Context) (context.Context, string) {
+	id := ""
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(requestIDMetadataKey); len(values) > 0 {
+			id = values[0]
+		}
+	}
+	if id == "" {
+		id = uuid.NewString()
+	}
+
+	ctx = context.WithValue(ctx, requestIDKey{}, id)
+	ctx = metadata.AppendToOutgoingContext(ctx, requestIDMetadataKey, id)
+	return ctx, id
+}
+
 type observer struct {
-	errs       *prometheus.CounterVec
-	requests   *prometheus.CounterVec
-	throughput *prometheus.CounterVec
-	active     prometheus.Gauge
-	logger     *zap.SugaredLogger
+	errs        *prometheus.CounterVec
+	requests    *prometheus.CounterVec
+	throughput  *prometheus.CounterVec
+	authFailure *prometheus.CounterVec
+	duration    *prometheus.HistogramVec
+	active      prometheus.Gauge
+	logger      *zap.SugaredLogger
 }
 
 func NewObserver(w io.Writer) *observer {
@@ -45,9 +83,17 @@ func NewObserver(w io.Writer) *observer {
 			Help: "Number of active Magnapinna client sessions.",
 		}),
 		throughput: prometheus.NewCounterVec(prometheus.CounterOpts{
-			Name: "grpc_errors",
-			Help: "Counter of gRPC errors by calling context in application.",
+			Name: "grpc_throughput_bytes",
+			Help: "Counter of gRPC message bytes observed, by calling context in application.",
 		}, []string{"caller"}),
+		authFailure: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "grpc_auth_failures",
+			Help: "Counter of gRPC calls rejected by authentication, by method.",
+		}, []string{"method"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "grpc_request_duration_seconds",
+			Help: "Histogram of gRPC request durations, by method and status code.",
+		}, []string{"method", "code"}),
 	}
 }
 
@@ -56,6 +102,9 @@ func (o *observer) Describe(desc chan<- *prometheus.Desc) {
 	o.requests.Describe(desc)
 	o.errs.Describe(desc)
 	o.active.Describe(desc)
+	o.authFailure.Describe(desc)
+	o.throughput.Describe(desc)
+	o.duration.Describe(desc)
 }
 
 // Collect is part of the implememtation of prometheus.Collector.
@@ -63,35 +112,54 @@ func (o *observer) Collect(coll chan<- prometheus.Metric) {
 	o.requests.Collect(coll)
 	o.errs.Collect(coll)
 	o.active.Collect(coll)
+	o.authFailure.Collect(coll)
+	o.throughput.Collect(coll)
+	o.duration.Collect(coll)
+}
+
+// ObserveAuthFailure encapsulates observability for calls rejected by the
+// auth interceptors.
+func (o *observer) ObserveAuthFailure(method string) {
+	o.authFailure.WithLabelValues(method).Inc()
+}
+
+// loggerFor returns o.logger with the correlation id attached to ctx (if any)
+// included as a structured field, so every log line for a request can be
+// traced back to it.
+func (o *observer) loggerFor(ctx context.Context) *zap.SugaredLogger {
+	if id, ok := CorrelationID(ctx); ok {
+		return o.logger.With("request_id", id)
+	}
+	return o.logger
 }
 
 // ObserveGRPCCall encapsulates observability for all gRPC calls - note that that is intended
 // to be used in a gRPC observer, and the API may change to expect values related to that.
-func (o *observer) ObserveGRPCCall(context string, err error) {
+func (o *observer) ObserveGRPCCall(ctx context.Context, context string, err error) {
 	if err != nil {
 		o.errs.WithLabelValues(context).Inc()
-		o.logger.Errorw("gRPC call failed", "err", err)
+		o.loggerFor(ctx).Errorw("gRPC call failed", "caller", context, "err", err)
 	}
 	o.requests.WithLabelValues(context).Inc()
 }
 
 // ObserveClientAddition encapsulates observability for ClientAddition calls
-func (o *observer) ObserveClientAddition(id string, err error) {
+func (o *observer) ObserveClientAddition(ctx context.Context, id string, err error) {
 	if err != nil {
-		o.logger.Infow("new client addition failed", "client", id, "error", err)
+		o.loggerFor(ctx).Infow("new client addition failed", "client", id, "error", err)
 	} else {
 		o.active.Inc()
-		o.logger.Infow("new client added", "client", id)
+		o.loggerFor(ctx).Infow("new client added", "client", id)
 	}
 }
 
 // ObserveClientDeletion encapsulates observability for ClientDeletion calls
-func (o *observer) ObserveClientDeletion(id string, err error) {
+func (o *observer) ObserveClientDeletion(ctx context.Context, id string, err error) {
 	if err != nil {
-		o.logger.Infow("new client deletion failed", "client", id, "error", err)
+		o.loggerFor(ctx).Infow("new client deletion failed", "client", id, "error", err)
 	} else {
 		o.active.Dec()
-		o.logger.Infow("client deleted", "client", id)
+		o.loggerFor(ctx).Infow("client deleted", "client", id)
 	}
 }
 
@@ -102,87 +170,115 @@ func (o *observer) ObserveThroughput(context string, length int) {
 
 // ObserveReceiveError encapsulates observability for stream receives that occur
 // in service code.
-func (o *observer) ObserveReceiveError(context string, err error) {
-	o.logger.Errorw("stream receive failed", "context", context, "error", err)
+func (o *observer) ObserveReceiveError(ctx context.Context, context string, err error) {
+	o.loggerFor(ctx).Errorw("stream receive failed", "context", context, "error", err)
 }
 
 // ObserveSendError encapsulates observability for stream sends that occur
 // in service code.
-func (o *observer) ObserveSendError(context string, err error) {
-	o.logger.Errorw("stream receive failed", "context", context, "error", err)
+func (o *observer) ObserveSendError(ctx context.Context, context string, err error) {
+	o.loggerFor(ctx).Errorw("stream send failed", "context", context, "error", err)
 }
 
 // UnaryObserver encapsulates observability to be applied to all unary gRPC calls.
-func (o *observer) UnaryObserver() func(ctx context.Context,
-	req interface{},
-	info *grpc.UnaryServerInfo,
-	handler grpc.UnaryHandler) (interface{}, error) {
-
+// It attaches a correlation id to ctx (propagating any existing x-request-id
+// from incoming metadata, or generating one), records request duration and
+// status code, and emits the existing request/error/client-lifecycle metrics.
+func (o *observer) UnaryObserver() grpc.UnaryServerInterceptor {
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, _ = withCorrelationID(ctx)
+		start := time.Now()
+
 		resp, err := handler(ctx, req)
-		o.ObserveGRPCCall(info.FullMethod, err)
+
+		o.duration.WithLabelValues(info.FullMethod, status.Code(err).String()).Observe(time.Since(start).Seconds())
+		o.ObserveGRPCCall(ctx, info.FullMethod, err)
 
 		// after executing and observing generic call, determine type so we can
 		// execute method-specific behavior
-		switch info.FullMethod {
-		case "/Magnapinna/Register":
-			//TODO fix this and below to get ID (maybe?) or move elsewhere
-			r, ok := req.(api.Registration)
-			if !ok {
-				return nil, fmt.Errorf("wrong type for gRPC endpoint: expected Registration, got %T", req)
-			}
-			o.ObserveClientAddition(r.Identifier, err)
-		case "/Magnapinna/Deregister":
-			r, ok := req.(api.Registration)
-			if !ok {
-				return nil, fmt.Errorf("wrong type for gRPC endpoint: expected Registration, got %T", req)
+		if r, ok := req.(*api.Registration); ok {
+			switch info.FullMethod {
+			case "/Magnapinna/Register":
+				o.ObserveClientAddition(ctx, r.Identifier, err)
+			case "/Magnapinna/Deregister":
+				o.ObserveClientDeletion(ctx, r.Identifier, err)
 			}
-			o.ObserveClientDeletion(r.Identifier, err)
 		}
 
 		return resp, err
 	}
 }
 
-// UnaryObserver encapsulates observability to be applied to all stream gRPC calls.
-func (o *observer) StreamObserver() func(srv interface{},
-	stream grpc.ServerStream,
-	info *grpc.StreamServerInfo,
-	handler grpc.StreamHandler) error {
+// observedServerStream wraps a grpc.ServerStream so that per-message
+// throughput can be measured as messages pass through the handler, rather
+// than by consuming a message out-of-band before the handler ever sees it.
+type observedServerStream struct {
+	grpc.ServerStream
+	observer *observer
+	method   string
+}
 
+func (s *observedServerStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.observer.ObserveThroughput(s.method, messageLength(m))
+	}
+	return err
+}
+
+func (s *observedServerStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.observer.ObserveThroughput(s.method, messageLength(m))
+	}
+	return err
+}
+
+// messageLength returns the byte length of the Contents field for the
+// message types carried by magnapinna's streaming RPCs, or 0 for anything
+// else.
+func messageLength(m interface{}) int {
+	switch v := m.(type) {
+	case *api.Command:
+		return len(v.Contents)
+	case *api.Output:
+		return len(v.Contents)
+	default:
+		return 0
+	}
+}
+
+// StreamObserver encapsulates observability to be applied to all stream gRPC calls.
+func (o *observer) StreamObserver() grpc.StreamServerInterceptor {
 	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
-		var i interface{}
-		err := stream.RecvMsg(i)
-		if err != nil {
-			return fmt.Errorf("failed to receive gRPC message: %w", err)
+		ctx, _ := withCorrelationID(stream.Context())
+		wrapped := &observedServerStream{
+			ServerStream: &correlatedServerStream{ServerStream: stream, ctx: ctx},
+			observer:     o,
+			method:       info.FullMethod,
 		}
 
-		// Determine method so we can type cast and get length
-		length := 0
-		switch info.FullMethod {
-		case "/Magnapinna/StartSession":
-			//TODO fix this and below to get ID (maybe?) or move elsewhere
-			r, ok := i.(api.Command)
-			if !ok {
-				return fmt.Errorf("wrong type for gRPC endpoint: expected Registration, got %T", i)
-			}
-			length = len(r.Contents)
-		case "/Magnapinna/JoinCluster":
-			r, ok := i.(api.Output)
-			if !ok {
-				return fmt.Errorf("wrong type for gRPC endpoint: expected Registration, got %T", i)
-			}
-			length = len(r.Contents)
-		}
-		o.ObserveThroughput(info.FullMethod, length)
+		start := time.Now()
+		err := handler(srv, wrapped)
 
-		// do call and observe result
-		err = handler(srv, stream)
-		o.ObserveGRPCCall(info.FullMethod, err)
+		o.duration.WithLabelValues(info.FullMethod, status.Code(err).String()).Observe(time.Since(start).Seconds())
+		o.ObserveGRPCCall(ctx, info.FullMethod, err)
 		return err
 	}
 }
 
+// correlatedServerStream overrides Context() to carry the correlation id
+// attached by withCorrelationID, since grpc.ServerStream does not expose a
+// way to replace its context in place.
+type correlatedServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *correlatedServerStream) Context() context.Context {
+	return s.ctx
+}
+
 // toZapKeys is a shim to deal with zap requiring specific strings for writing to stdout/err, or not writing at all
 func toZapKeys(w io.Writer) []string {
 	switch w {
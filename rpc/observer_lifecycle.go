@@ -0,0 +1,128 @@
+package rpc
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+
+	"magnapinna/logger"
+)
+
+// Observer is a structured, lease-lifecycle-focused logging interface,
+// decoupled from the gRPC-specific metrics/interceptor machinery in
+// observer.go. Repository implementations (e.g. repository/etcd) and the
+// controller subsystem can depend on just this interface, without pulling
+// in the rest of the server's observability plumbing.
+type Observer interface {
+	// RegisterAttempted is called before a Register RPC is dispatched to the
+	// repository.
+	RegisterAttempted(ctx context.Context, identifier string)
+	// RegisterSucceeded is called once a registration has been durably
+	// stored, recording how long the round trip took.
+	RegisterSucceeded(ctx context.Context, identifier string, duration time.Duration)
+	// LeaseExpired is called when a lease is found to have expired, whether
+	// by an explicit sweep or by a backend's own TTL mechanism.
+	LeaseExpired(ctx context.Context, identifier string)
+	// RepositoryError is called when a Repository method returns an error,
+	// tagging it with the operation that failed (e.g. "StoreLease").
+	RepositoryError(ctx context.Context, operation string, err error)
+	// ControllerError is called when a background Controller's Run returns
+	// an error, tagging it with the controller's Name().
+	ControllerError(ctx context.Context, controller string, err error)
+}
+
+// ServerObserver is the observability surface Server depends on: the narrow
+// Observer used by the repository/controller subsystem, plus the gRPC
+// request/connection-level events that Server's handlers and interceptors
+// emit directly. *observer is the only implementation in this package, but
+// Server depending on the interface lets NewServer callers supply their own
+// (e.g. a test double) instead.
+type ServerObserver interface {
+	Observer
+	ObserveGRPCCall(ctx context.Context, method string, err error)
+	ObserveClientAddition(ctx context.Context, identifier string, err error)
+	ObserveClientDeletion(ctx context.Context, identifier string, err error)
+	ObserveSendError(ctx context.Context, method string, err error)
+	ObserveReceiveError(ctx context.Context, method string, err error)
+	ObserveAuthFailure(method string)
+	UnaryObserver() grpc.UnaryServerInterceptor
+	StreamObserver() grpc.StreamServerInterceptor
+	UnaryAuthInterceptor(auth *authConfig) grpc.UnaryServerInterceptor
+	StreamAuthInterceptor(auth *authConfig) grpc.StreamServerInterceptor
+}
+
+// NopObserver implements Observer by discarding every event, for tests and
+// callers that don't want lease-lifecycle logging.
+type NopObserver struct{}
+
+func (NopObserver) RegisterAttempted(ctx context.Context, identifier string)                  {}
+func (NopObserver) RegisterSucceeded(ctx context.Context, identifier string, d time.Duration) {}
+func (NopObserver) LeaseExpired(ctx context.Context, identifier string)                       {}
+func (NopObserver) RepositoryError(ctx context.Context, operation string, err error)          {}
+func (NopObserver) ControllerError(ctx context.Context, controller string, err error)         {}
+
+// fieldsFor builds the structured zap fields common to every Observer event
+// on o: the request's correlation id (if any) and peer address (if any).
+func (o *observer) fieldsFor(ctx context.Context) []interface{} {
+	fields := []interface{}{}
+	if id, ok := CorrelationID(ctx); ok {
+		fields = append(fields, "request_id", id)
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		fields = append(fields, "peer", p.Addr.String())
+	}
+	return fields
+}
+
+// RegisterAttempted implements Observer.
+func (o *observer) RegisterAttempted(ctx context.Context, identifier string) {
+	o.loggerFor(ctx).Infow("register attempted", append(o.fieldsFor(ctx), "identifier", identifier)...)
+}
+
+// RegisterSucceeded implements Observer.
+func (o *observer) RegisterSucceeded(ctx context.Context, identifier string, duration time.Duration) {
+	o.loggerFor(ctx).Infow("register succeeded", append(o.fieldsFor(ctx), "identifier", identifier, "duration", duration.String())...)
+}
+
+// LeaseExpired implements Observer.
+func (o *observer) LeaseExpired(ctx context.Context, identifier string) {
+	o.loggerFor(ctx).Infow("lease expired", append(o.fieldsFor(ctx), "identifier", identifier)...)
+}
+
+// RepositoryError implements Observer.
+func (o *observer) RepositoryError(ctx context.Context, operation string, err error) {
+	o.loggerFor(ctx).Errorw("repository operation failed", append(o.fieldsFor(ctx), "operation", operation, "error_class", errorClass(err), "error", err)...)
+}
+
+// ControllerError implements Observer.
+func (o *observer) ControllerError(ctx context.Context, controller string, err error) {
+	o.loggerFor(ctx).Errorw("controller run failed", append(o.fieldsFor(ctx), "controller", controller, "error_class", errorClass(err), "error", err)...)
+}
+
+// errorClass classifies err for structured logging, without leaking
+// backend-specific details into the class label itself.
+func errorClass(err error) string {
+	switch err.(type) {
+	case RepositoryError:
+		return "repository"
+	case ValidationError:
+		return "validation"
+	default:
+		return "unknown"
+	}
+}
+
+// GlobalLogger returns the package-level logger.L(), annotated with ctx's
+// correlation id if present, for call sites (e.g. Repository
+// implementations) that have no *observer of their own but still want
+// correlated structured log lines.
+func GlobalLogger(ctx context.Context) *zap.SugaredLogger {
+	l := logger.L()
+	if id, ok := CorrelationID(ctx); ok {
+		l = l.With("request_id", id)
+	}
+	return l
+}
@@ -0,0 +1,74 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	zapobserver "go.uber.org/zap/zaptest/observer"
+)
+
+// newTestObserver builds an *observer backed by a zaptest/observer core, so
+// tests can assert on structured fields instead of scraping opaque log
+// bytes.
+func newTestObserver() (*observer, *zapobserver.ObservedLogs) {
+	core, logs := zapobserver.New(zapcore.DebugLevel)
+	return &observer{logger: zap.New(core).Sugar()}, logs
+}
+
+func TestRegisterLifecycleStructuredFields(t *testing.T) {
+	obs, logs := newTestObserver()
+	ctx := context.Background()
+
+	obs.RegisterAttempted(ctx, "foo")
+	obs.RegisterSucceeded(ctx, "foo", 0)
+
+	entries := logs.All()
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 log entries, got %d", len(entries))
+	}
+
+	if got := entries[0].ContextMap()["identifier"]; got != "foo" {
+		t.Errorf("expected identifier field %q, got %q", "foo", got)
+	}
+	if _, ok := entries[1].ContextMap()["duration"]; !ok {
+		t.Errorf("expected duration field on RegisterSucceeded entry, got %+v", entries[1].ContextMap())
+	}
+}
+
+func TestRepositoryErrorStructuredFields(t *testing.T) {
+	obs, logs := newTestObserver()
+	ctx := context.Background()
+
+	obs.RepositoryError(ctx, "StoreLease", RepositoryError{s: "boom"})
+
+	entries := logs.All()
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 log entry, got %d", len(entries))
+	}
+	fields := entries[0].ContextMap()
+	if fields["operation"] != "StoreLease" {
+		t.Errorf("expected operation field %q, got %q", "StoreLease", fields["operation"])
+	}
+	if fields["error_class"] != "repository" {
+		t.Errorf("expected error_class field %q, got %q", "repository", fields["error_class"])
+	}
+}
+
+func TestErrorClass(t *testing.T) {
+	cases := []struct {
+		err  error
+		want string
+	}{
+		{RepositoryError{s: "x"}, "repository"},
+		{ValidationError{s: "x"}, "validation"},
+		{errors.New("other"), "unknown"},
+	}
+	for _, c := range cases {
+		if got := errorClass(c.err); got != c.want {
+			t.Errorf("errorClass(%v) = %q, want %q", c.err, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package rpc
+
+import (
+	"context"
+	"time"
+)
+
+// defaultReapInterval is used by NewExpirationReaper if interval is zero.
+const defaultReapInterval = 30 * time.Second
+
+// ExpirationReaper is a Controller that periodically scans a Repository for
+// expired leases, deletes them, and reports each via Observer.LeaseExpired.
+// It exists because Repository.StoreLease writes api.Lease.Expiration but
+// nothing otherwise enforces it outside the RPC path (CheckRegistration
+// simply returns whatever is stored).
+type ExpirationReaper struct {
+	repository Repository
+	observer   Observer
+	clock      Clock
+	interval   time.Duration
+}
+
+// NewExpirationReaper returns an ExpirationReaper polling repository every
+// interval (defaultReapInterval if zero). clock determines what "now" means
+// when deciding a lease has expired; pass nil to use the wall clock, or a
+// fake Clock in tests to drive expiration deterministically.
+func NewExpirationReaper(repository Repository, observer Observer, clock Clock, interval time.Duration) *ExpirationReaper {
+	if interval == 0 {
+		interval = defaultReapInterval
+	}
+	if clock == nil {
+		clock = realClock{}
+	}
+	return &ExpirationReaper{
+		repository: repository,
+		observer:   observer,
+		clock:      clock,
+		interval:   interval,
+	}
+}
+
+// Name implements Controller.
+func (r *ExpirationReaper) Name() string { return "expiration_reaper" }
+
+// Interval implements Controller.
+func (r *ExpirationReaper) Interval() time.Duration { return r.interval }
+
+// Run implements Controller.
+func (r *ExpirationReaper) Run(ctx context.Context) error {
+	expired, err := r.repository.ListExpiredLeases(ctx, r.clock.Now())
+	if err != nil {
+		return err
+	}
+
+	for _, lease := range expired {
+		if err := r.repository.DeleteLease(ctx, lease); err != nil {
+			r.observer.RepositoryError(ctx, "DeleteLease", err)
+			continue
+		}
+		r.observer.LeaseExpired(ctx, lease.Identifier)
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package rpc
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"magnapinna/api"
+)
+
+// fakeClock is a Clock whose Now() is set explicitly by tests, so
+// expiration can be driven deterministically instead of sleeping for real
+// intervals.
+type fakeClock struct {
+	mut sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) set(t time.Time) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.now = t
+}
+
+// fakeReaperRepository implements Repository with just enough behavior for
+// ExpirationReaper: ListExpiredLeases filters an in-memory slice, DeleteLease
+// removes the matching entry.
+type fakeReaperRepository struct {
+	mut    sync.Mutex
+	leases []*api.Lease
+}
+
+func (r *fakeReaperRepository) StoreLease(ctx context.Context, lease *api.Lease) error {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	r.leases = append(r.leases, lease)
+	return nil
+}
+
+func (r *fakeReaperRepository) FetchLease(ctx context.Context, registration *api.Registration) (*api.Lease, error) {
+	return nil, ErrNoLease
+}
+
+func (r *fakeReaperRepository) DeleteLease(ctx context.Context, lease *api.Lease) error {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	for i, l := range r.leases {
+		if l.Identifier == lease.Identifier {
+			r.leases = append(r.leases[:i], r.leases[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (r *fakeReaperRepository) ListExpiredLeases(ctx context.Context, now time.Time) ([]*api.Lease, error) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	var expired []*api.Lease
+	for _, l := range r.leases {
+		if l.Expiration <= now.Unix() {
+			expired = append(expired, l)
+		}
+	}
+	return expired, nil
+}
+
+// testObserver records the identifiers reported via LeaseExpired, for
+// assertions in TestExpirationReaperRun.
+type testObserver struct {
+	NopObserver
+	mut     sync.Mutex
+	expired []string
+}
+
+func (o *testObserver) LeaseExpired(ctx context.Context, identifier string) {
+	o.mut.Lock()
+	defer o.mut.Unlock()
+	o.expired = append(o.expired, identifier)
+}
+
+func TestExpirationReaperRun(t *testing.T) {
+	repo := &fakeReaperRepository{
+		leases: []*api.Lease{
+			{Identifier: "expired", Expiration: 1000},
+			{Identifier: "live", Expiration: 2000},
+		},
+	}
+	obs := &testObserver{}
+	clock := &fakeClock{now: time.Unix(1500, 0)}
+
+	reaper := NewExpirationReaper(repo, obs, clock, time.Second)
+	if err := reaper.Run(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if len(obs.expired) != 1 || obs.expired[0] != "expired" {
+		t.Errorf("expected only \"expired\" to be reported, got %v", obs.expired)
+	}
+	if len(repo.leases) != 1 || repo.leases[0].Identifier != "live" {
+		t.Errorf("expected \"live\" lease to remain, got %+v", repo.leases)
+	}
+
+	// advancing the clock past "live"'s expiration should reap it too.
+	clock.set(time.Unix(2500, 0))
+	if err := reaper.Run(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if len(repo.leases) != 0 {
+		t.Errorf("expected no leases to remain, got %+v", repo.leases)
+	}
+}
+
+func TestManagerRunsAndStops(t *testing.T) {
+	repo := &fakeReaperRepository{leases: []*api.Lease{{Identifier: "expired", Expiration: 0}}}
+	obs := &testObserver{}
+	clock := &fakeClock{now: time.Unix(1, 0)}
+	reaper := NewExpirationReaper(repo, obs, clock, time.Millisecond)
+
+	manager := NewManager(obs, reaper)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan struct{})
+	go func() {
+		manager.Run(ctx)
+		close(done)
+	}()
+
+	deadline := time.After(time.Second)
+	for {
+		obs.mut.Lock()
+		n := len(obs.expired)
+		obs.mut.Unlock()
+		if n > 0 {
+			break
+		}
+		select {
+		case <-deadline:
+			t.Fatal("timed out waiting for ExpirationReaper to run via Manager")
+		case <-time.After(time.Millisecond):
+		}
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Manager.Run did not return after context cancellation")
+	}
+}
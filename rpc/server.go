@@ -4,33 +4,57 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"magnapinna/api"
-	"sync"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
 	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"magnapinna/api"
+	"magnapinna/config"
+	"magnapinna/logger"
+	"magnapinna/service"
 )
 
 type Repository interface {
 	StoreLease(context.Context, *api.Lease) error
 	FetchLease(context.Context, *api.Registration) (*api.Lease, error)
 	DeleteLease(context.Context, *api.Lease) error
+	// ListExpiredLeases returns every stored lease whose Expiration is at or
+	// before now, for use by the expiration reaper controller.
+	ListExpiredLeases(ctx context.Context, now time.Time) ([]*api.Lease, error)
+}
+
+// Watcher is implemented by Repository backends that can notify the server
+// when a lease is revoked out from under them - e.g. etcd's own TTL expiry -
+// as opposed to only through an explicit DeleteLease call. It's optional:
+// Server type-asserts for it and simply skips watching if a Repository
+// doesn't implement it.
+type Watcher interface {
+	// Watch blocks, calling onRevoke with the identifier of any lease
+	// revoked, until ctx is cancelled.
+	Watch(ctx context.Context, onRevoke func(identifier string))
 }
 
 type Server struct {
+	*service.BaseService
 	api.UnimplementedMagnapinnaServer
+	cfg        config.ServerConfig
 	srv        *grpc.Server
+	listener   net.Listener
 	ctx        context.Context
-	timeout    time.Duration
+	cancelCtx  context.CancelFunc
 	conns      ConnCache
 	repository Repository
-	observer   *observer
-}
-
-type ConnCache struct {
-	mut    *sync.Mutex
-	active map[string]api.Magnapinna_JoinClusterServer
+	observer   ServerObserver
+	manager    *Manager
+	errors     chan error
 }
 
 type RepositoryError struct {
@@ -54,6 +78,13 @@ func (r RepositoryError) Sanitized() string {
 	return repoErr
 }
 
+// Wrap returns a RepositoryError carrying err's message, for Repository
+// implementations translating backend-specific errors into the type callers
+// of this package already know how to sanitize.
+func (r RepositoryError) Wrap(err error) RepositoryError {
+	return RepositoryError{s: err.Error()}
+}
+
 func (v ValidationError) Error() string {
 	return fmt.Sprintf("%s: %s", validErr, v.s)
 }
@@ -62,6 +93,152 @@ func (v ValidationError) Sanitized() string {
 	return validErr
 }
 
+// serverErrorBufferSize bounds the Server.errors channel so that errors from
+// the serve loop can't block OnStop if nothing is draining Errors() yet.
+const serverErrorBufferSize = 8
+
+// NewServer constructs a Server from cfg, listening on cfg.ListenAddr once
+// Start is called. repository is constructed by the caller so that it can
+// choose between e.g. the in-memory repository/memory implementation for
+// tests and the durable repository/etcd implementation in production;
+// cfg.RepositoryBackend exists for that caller to branch on, not for
+// NewServer itself.
+//
+// If cfg.JWKSURL is set, calls are required to present a bearer token in the
+// "authorization" metadata that it can validate, except for the methods
+// listed in cfg.UnauthenticatedMethods. Leave it empty to run without
+// authentication, e.g. in tests.
+//
+// If cfg.ExpirationSweepInterval is nonzero, an ExpirationReaper controller
+// is started alongside the gRPC listener to reap leases the repository
+// reports as expired.
+//
+// cfg.Observer configures the global logger (see magnapinna/logger.Setup)
+// as well as where the server's own request/lifecycle logging goes; an
+// unset cfg.Observer discards the latter, matching prior behavior.
+//
+// observer, if given, overrides the zap-backed ServerObserver NewServer
+// would otherwise build from cfg.Observer - for tests, or callers that want
+// to plug in their own structured logger. At most one may be given.
+func NewServer(cfg config.ServerConfig, repository Repository, observer ...ServerObserver) (*Server, error) {
+	if len(observer) > 1 {
+		return nil, fmt.Errorf("NewServer: at most one ServerObserver may be given, got %d", len(observer))
+	}
+
+	listener, err := net.Listen("tcp", cfg.ListenAddr)
+	if err != nil {
+		return nil, fmt.Errorf("listening on %s: %w", cfg.ListenAddr, err)
+	}
+
+	var obs ServerObserver
+	if len(observer) == 1 {
+		obs = observer[0]
+	} else {
+		if err := logger.Setup(logger.Config{Level: cfg.Observer.Level, OutputPaths: cfg.Observer.OutputPaths}); err != nil {
+			return nil, fmt.Errorf("configuring logger: %w", err)
+		}
+		obs = NewObserver(observerOutput(cfg.Observer.OutputPaths))
+	}
+
+	var opts []grpc.ServerOption
+	if cfg.JWKSURL != "" {
+		authenticator, err := NewJWTAuthenticator(context.Background(), cfg.JWKSURL)
+		if err != nil {
+			return nil, fmt.Errorf("building JWT authenticator: %w", err)
+		}
+		auth := newAuthConfig(authenticator, cfg.UnauthenticatedMethods)
+		opts = append(opts,
+			grpc.ChainUnaryInterceptor(obs.UnaryObserver(), obs.UnaryAuthInterceptor(auth)),
+			grpc.ChainStreamInterceptor(obs.StreamObserver(), obs.StreamAuthInterceptor(auth)),
+		)
+	} else {
+		opts = append(opts,
+			grpc.ChainUnaryInterceptor(obs.UnaryObserver()),
+			grpc.ChainStreamInterceptor(obs.StreamObserver()),
+		)
+	}
+	if d := cfg.RPCTimeout.Duration; d > 0 {
+		// bound each unary RPC's context by cfg.RPCTimeout.
+		opts = append(opts, grpc.ChainUnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			ctx, cancel := context.WithTimeout(ctx, d)
+			defer cancel()
+			return handler(ctx, req)
+		}))
+	}
+
+	var controllers []Controller
+	if cfg.ExpirationSweepInterval.Duration > 0 {
+		controllers = append(controllers, NewExpirationReaper(repository, obs, nil, cfg.ExpirationSweepInterval.Duration))
+	}
+
+	grpcSrv := grpc.NewServer(opts...)
+	s := &Server{
+		cfg:        cfg,
+		srv:        grpcSrv,
+		listener:   listener,
+		conns:      NewConnCache(0),
+		repository: repository,
+		observer:   obs,
+		manager:    NewManager(obs, controllers...),
+		errors:     make(chan error, serverErrorBufferSize),
+	}
+	s.BaseService = service.NewBaseService("Server", s)
+	api.RegisterMagnapinnaServer(grpcSrv, s)
+	grpc_health_v1.RegisterHealthServer(grpcSrv, newHealthServer(&s.conns))
+	return s, nil
+}
+
+// Errors returns a read-only channel of errors encountered by the server's
+// background serve loop.
+func (s *Server) Errors() <-chan error {
+	return s.errors
+}
+
+// Addr returns the address the server is listening on, useful when
+// cfg.ListenAddr uses an ephemeral port (e.g. "127.0.0.1:0") and the caller
+// needs to discover the one actually bound.
+func (s *Server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// OnStart implements service.Impl, starting the gRPC serve loop against the
+// listener built from cfg.ListenAddr, and any registered controllers. The
+// context passed to Start becomes the parent of every RPC-scoped deadline
+// and of the controllers' run loop, so cancelling it (or calling Stop) tears
+// both down together.
+func (s *Server) OnStart(ctx context.Context) error {
+	s.ctx, s.cancelCtx = context.WithCancel(ctx)
+
+	go func() {
+		if err := s.srv.Serve(s.listener); err != nil {
+			s.errors <- err
+		}
+	}()
+	go s.manager.Run(s.ctx)
+	if w, ok := s.repository.(Watcher); ok {
+		go w.Watch(s.ctx, s.onLeaseRevoked)
+	}
+	return nil
+}
+
+// onLeaseRevoked is passed to the repository's Watch, if it implements
+// Watcher, so a lease revoked out from under the server (rather than
+// through an explicit Deregister) still shows up in observability. A
+// Watcher that reports every key deletion, as repository/etcd does, also
+// reports leases removed by Deregister or by the ExpirationReaper, and
+// those reach LeaseExpired here as well.
+func (s *Server) onLeaseRevoked(identifier string) {
+	s.observer.LeaseExpired(s.ctx, identifier)
+}
+
+// OnStop implements service.Impl, gracefully draining in-flight RPCs and
+// closing the listener, and stopping any registered controllers.
+func (s *Server) OnStop() error {
+	s.cancelCtx()
+	s.srv.GracefulStop()
+	return nil
+}
+
 func (s *Server) CheckRegistration(ctx context.Context, rs *api.Registration) (*api.Lease, error) {
 	if !rsValid(rs) {
 		return nil, ValidationError{s: "missing required fields"}
@@ -73,15 +250,27 @@ func (s *Server) Register(ctx context.Context, rs *api.Registration) (*api.Lease
 	if !rsValid(rs) {
 		return nil, ValidationError{s: "missing required fields"}
 	}
+	start := time.Now()
+	s.observer.RegisterAttempted(ctx, rs.Identifier)
+
 	lease, err := s.repository.FetchLease(ctx, rs)
 	if err != nil && err != ErrNoLease {
+		s.observer.RepositoryError(ctx, "FetchLease", err)
 		return nil, err
 	}
+	if lease == nil {
+		lease = &api.Lease{}
+	}
 
-	lease.Expiration = time.Now().Unix() + int64(rs.Duration)
+	lease.Expiration = time.Now().Unix() + int64(s.leaseDuration(rs.Duration).Seconds())
 	lease.Identifier = rs.Identifier
-	err = s.repository.StoreLease(ctx, lease)
-	return lease, err
+	if err := s.repository.StoreLease(ctx, lease); err != nil {
+		s.observer.RepositoryError(ctx, "StoreLease", err)
+		return nil, err
+	}
+
+	s.observer.RegisterSucceeded(ctx, rs.Identifier, time.Since(start))
+	return lease, nil
 }
 
 func (s *Server) Deregister(ctx context.Context, rs *api.Registration) (*api.Lease, error) {
@@ -90,23 +279,32 @@ func (s *Server) Deregister(ctx context.Context, rs *api.Registration) (*api.Lea
 	}
 	lease, err := s.repository.FetchLease(ctx, rs)
 	if err != nil && err != ErrNoLease {
+		s.observer.RepositoryError(ctx, "FetchLease", err)
 		return nil, err
 	} else if err == ErrNoLease {
 		return &api.Lease{}, nil
 	}
 
-	err = s.repository.DeleteLease(ctx, lease)
-	return lease, err
+	if err := s.repository.DeleteLease(ctx, lease); err != nil {
+		s.observer.RepositoryError(ctx, "DeleteLease", err)
+		return nil, err
+	}
+	return lease, nil
 }
 
 func (s *Server) JoinCluster(join api.Magnapinna_JoinClusterServer) error {
+	streamCtx := join.Context()
 	init, err := join.Recv()
 	if err != nil {
-		s.observer.ObserveGRPCCall("join_cluster_recv", err)
+		s.observer.ObserveGRPCCall(streamCtx, "join_cluster_recv", err)
+		return err
+	}
+	if err := checkSubjectMatch(streamCtx, init.Identifier); err != nil {
+		s.observer.ObserveAuthFailure("/Magnapinna/JoinCluster")
 		return err
 	}
 
-	ctx, cancel := context.WithTimeout(s.ctx, s.timeout)
+	ctx, cancel := context.WithTimeout(s.ctx, s.cfg.RPCTimeout.Duration)
 	_, err = s.CheckRegistration(ctx, &api.Registration{
 		Identifier: init.Identifier,
 	})
@@ -118,25 +316,29 @@ func (s *Server) JoinCluster(join api.Magnapinna_JoinClusterServer) error {
 
 	// Hand off JoinClusterServer to be used in StartSession calls, then await
 	// context cancellation prior to setting Trailer and returning.
-	err = s.conns.addClient(init.Identifier, join)
-	if err != nil {
-		return err
-	}
-	s.observer.ObserveClientAddition(init.Identifier)
+	entry := s.conns.addClient(init.Identifier, join)
+	s.observer.ObserveClientAddition(streamCtx, init.Identifier, nil)
 
-	done := join.Context().Done()
+	done := streamCtx.Done()
 	<-done
+	s.conns.removeEntry(init.Identifier, entry)
+	s.observer.ObserveClientDeletion(streamCtx, init.Identifier, nil)
 	join.SetTrailer(metadata.New(map[string]string{"closed": "true"}))
 	return nil
 }
 
 func (s *Server) StartSession(sess api.Magnapinna_StartSessionServer) error {
+	streamCtx := sess.Context()
 	init, err := sess.Recv()
 	if err != nil {
-		s.observer.ObserveGRPCCall("start_session_init_recv", err)
+		s.observer.ObserveGRPCCall(streamCtx, "start_session_init_recv", err)
 		return err
 	}
-	ctx, cancel := context.WithTimeout(s.ctx, s.timeout)
+	if err := checkSubjectMatch(streamCtx, init.Identifier); err != nil {
+		s.observer.ObserveAuthFailure("/Magnapinna/StartSession")
+		return err
+	}
+	ctx, cancel := context.WithTimeout(s.ctx, s.cfg.RPCTimeout.Duration)
 	_, err = s.CheckRegistration(ctx, &api.Registration{
 		Identifier: init.Identifier,
 	})
@@ -145,10 +347,6 @@ func (s *Server) StartSession(sess api.Magnapinna_StartSessionServer) error {
 		return err
 	}
 
-	remote, err := s.conns.getClient(init.Identifier)
-	if err != nil {
-		return err
-	}
 	done := s.ctx.Done()
 
 	for {
@@ -157,52 +355,91 @@ func (s *Server) StartSession(sess api.Magnapinna_StartSessionServer) error {
 			sess.SetTrailer(metadata.New(map[string]string{"closed": "true"}))
 			return nil
 		default:
-			// TODO handle back off if we're erroring sequentially
 			// Note that this serially sends the command and waits for a response -
 			// this is to prevent potentially clobbering input/output from other commands
 			cmd, err := sess.Recv()
 			if err != nil {
-				s.observer.ObserveGRPCCall("start_session_recv", err)
-			}
-			err = remote.Send(cmd)
-			if err != nil {
-				s.observer.ObserveGRPCCall("start_session_cmd_send", err)
+				s.observer.ObserveGRPCCall(streamCtx, "start_session_recv", err)
+				return err
 			}
-			output, err := remote.Recv()
+
+			output, err := s.dispatch(streamCtx, init.Identifier, cmd)
 			if err != nil {
-				s.observer.ObserveGRPCCall("start_session_output_recv", err)
+				s.observer.ObserveGRPCCall(streamCtx, "start_session_dispatch", err)
+				sess.SetTrailer(metadata.New(map[string]string{"no-healthy-backend": "true"}))
+				return err
 			}
-			err = sess.Send(output)
-			if err != nil {
-				s.observer.ObserveGRPCCall("start_session_output_send", err)
+
+			if err := sess.Send(output); err != nil {
+				s.observer.ObserveGRPCCall(streamCtx, "start_session_output_send", err)
 			}
 		}
 	}
 }
 
-func (c *ConnCache) addClient(id string, join api.Magnapinna_JoinClusterServer) error {
-	c.mut.Lock()
-	defer c.mut.Unlock()
-	_, found := c.active[id]
-	if found {
-		return fmt.Errorf("ID %s has already connected", id)
+// dispatch sends cmd to a healthy JoinCluster stream for id and returns its
+// response, retrying against another stream if the chosen one fails to send
+// or receive, up to maxDispatchAttempts times.
+func (s *Server) dispatch(ctx context.Context, id string, cmd *api.Command) (*api.Output, error) {
+	var lastErr error
+	for attempt := 0; attempt < maxDispatchAttempts; attempt++ {
+		entry, err := s.conns.pick(id)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := entry.send(cmd); err != nil {
+			s.observer.ObserveSendError(ctx, "start_session_cmd_send", err)
+			s.conns.removeEntry(id, entry)
+			lastErr = err
+			continue
+		}
+
+		output, err := entry.recv()
+		if err != nil {
+			s.observer.ObserveReceiveError(ctx, "start_session_output_recv", err)
+			s.conns.removeEntry(id, entry)
+			lastErr = err
+			continue
+		}
+
+		return output, nil
 	}
-	c.active[id] = join
-	return nil
+	return nil, status.Errorf(codes.Unavailable, "no healthy backend for identifier %s after %d attempts: %v", id, maxDispatchAttempts, lastErr)
 }
 
-func (c *ConnCache) getClient(id string) (api.Magnapinna_JoinClusterServer, error) {
-	c.mut.Lock()
-	defer c.mut.Unlock()
-	client, found := c.active[id]
-	if !found {
-		return nil, fmt.Errorf("no action client with ID %s", id)
+// leaseDuration resolves the lease length a Register call should use:
+// requested, falling back to cfg.LeaseDefaultDuration if unset, capped at
+// cfg.LeaseMaxDuration if that's configured and smaller.
+func (s *Server) leaseDuration(requested int32) time.Duration {
+	duration := time.Duration(requested) * time.Second
+	if duration == 0 {
+		duration = s.cfg.LeaseDefaultDuration.Duration
+	}
+	if max := s.cfg.LeaseMaxDuration.Duration; max > 0 && duration > max {
+		duration = max
+	}
+	return duration
+}
+
+// observerOutput resolves cfg.Observer.OutputPaths into the io.Writer
+// NewObserver expects; zap's "stdout"/"stderr" sink names don't correspond
+// to Go's io.Writer values, so this bridges the two. An unset OutputPaths
+// discards logging, matching NewServer's previous hardcoded default.
+func observerOutput(paths []string) io.Writer {
+	for _, p := range paths {
+		if p == "stderr" {
+			return os.Stderr
+		}
+	}
+	if len(paths) > 0 {
+		return os.Stdout
 	}
-	return client, nil
+	return ioutil.Discard
 }
 
 func rsValid(rs *api.Registration) bool {
-	return rs.Duration != 0 && rs.Identifier != ""
+	return rs.Identifier != ""
 }
 
 func leaseValid(lease *api.Lease) bool {
@@ -4,6 +4,9 @@ import (
 	"context"
 	"io/ioutil"
 	"magnapinna/api"
+	"magnapinna/config"
+	"os"
+	"sync"
 	"testing"
 	"time"
 
@@ -26,26 +29,132 @@ func (t *testRepository) FetchLease(ctx context.Context, registration *api.Regis
 func (t *testRepository) DeleteLease(ctx context.Context, lease *api.Lease) error {
 	return t.delete(ctx, lease)
 }
+func (t *testRepository) ListExpiredLeases(ctx context.Context, now time.Time) ([]*api.Lease, error) {
+	return nil, nil
+}
 
 // delete and store have the same function signature so will be using the same functions for them
-// positive cases have a very short sleep to test function cancellation
-func leasePositive(ctx context.Context, lease *api.Lease) error {
-	time.Sleep(time.Millisecond)
-	select {
-	case <-ctx.Done():
-		return context.DeadlineExceeded
-	default:
-		return nil
+// positive cases sleep for a configured duration to test context cancellation;
+// the test cases drive this via srv.cfg.RPCTimeout rather than a hardcoded sleep,
+// so the timeout case can be exercised without slowing down the positive ones.
+func leasePositive(sleep time.Duration) func(ctx context.Context, lease *api.Lease) error {
+	return func(ctx context.Context, lease *api.Lease) error {
+		time.Sleep(sleep)
+		select {
+		case <-ctx.Done():
+			return context.DeadlineExceeded
+		default:
+			return nil
+		}
+	}
+}
+
+func fetchPositive(sleep time.Duration) func(ctx context.Context, registration *api.Registration) (*api.Lease, error) {
+	return func(ctx context.Context, registration *api.Registration) (*api.Lease, error) {
+		time.Sleep(sleep)
+		select {
+		case <-ctx.Done():
+			return nil, context.DeadlineExceeded
+		default:
+			return &api.Lease{}, nil
+		}
+	}
+}
+
+func TestObserverOutput(t *testing.T) {
+	cases := []struct {
+		name  string
+		paths []string
+		want  interface{}
+	}{
+		{name: "unset discards", paths: nil, want: ioutil.Discard},
+		{name: "stdout", paths: []string{"stdout"}, want: os.Stdout},
+		{name: "stderr", paths: []string{"stderr"}, want: os.Stderr},
+		{name: "file path falls back to stdout", paths: []string{"/var/log/magnapinna.log"}, want: os.Stdout},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := observerOutput(c.paths); got != c.want {
+				t.Errorf("expected %v, got %v", c.want, got)
+			}
+		})
+	}
+}
+
+// watchingRepository is a Repository that also implements Watcher, calling
+// onRevoke once with a fixed identifier as soon as Watch is invoked, then
+// blocking until ctx is cancelled like a real implementation would.
+type watchingRepository struct {
+	testRepository
+	revoked string
+}
+
+func (w *watchingRepository) Watch(ctx context.Context, onRevoke func(identifier string)) {
+	onRevoke(w.revoked)
+	<-ctx.Done()
+}
+
+// recordingObserver wraps an Observer, recording every identifier passed to
+// LeaseExpired so tests can assert on it without a real logging backend.
+type recordingObserver struct {
+	ServerObserver
+	mu      sync.Mutex
+	expired []string
+}
+
+func (r *recordingObserver) LeaseExpired(ctx context.Context, identifier string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.expired = append(r.expired, identifier)
+}
+
+func TestServerWatchesRepositoryForRevocations(t *testing.T) {
+	obs := &recordingObserver{ServerObserver: NewObserver(ioutil.Discard)}
+	repo := &watchingRepository{revoked: "foo"}
+
+	srv, err := NewServer(config.ServerConfig{ListenAddr: "127.0.0.1:0"}, repo, obs)
+	if err != nil {
+		t.Fatalf("failed to build server: %s", err.Error())
+	}
+	if err := srv.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start server: %s", err.Error())
+	}
+	defer srv.Stop()
+
+	deadline := time.After(time.Second)
+	for {
+		obs.mu.Lock()
+		got := append([]string(nil), obs.expired...)
+		obs.mu.Unlock()
+
+		if len(got) == 1 && got[0] == "foo" {
+			return
+		}
+		select {
+		case <-deadline:
+			t.Fatalf("expected LeaseExpired(\"foo\") to be observed, got %v", got)
+		case <-time.After(time.Millisecond):
+		}
 	}
 }
 
-func fetchPositive(ctx context.Context, registration *api.Registration) (*api.Lease, error) {
-	time.Sleep(time.Millisecond)
-	select {
-	case <-ctx.Done():
-		return nil, context.DeadlineExceeded
-	default:
-		return &api.Lease{}, nil
+func TestNewServerAcceptsCustomObserver(t *testing.T) {
+	custom := NewObserver(ioutil.Discard)
+
+	srv, err := NewServer(config.ServerConfig{ListenAddr: "127.0.0.1:0"}, &testRepository{}, custom)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if srv.observer != custom {
+		t.Error("expected NewServer to use the given ServerObserver instead of building its own")
+	}
+}
+
+func TestNewServerRejectsMultipleObservers(t *testing.T) {
+	_, err := NewServer(config.ServerConfig{ListenAddr: "127.0.0.1:0"}, &testRepository{}, NewObserver(ioutil.Discard), NewObserver(ioutil.Discard))
+	if err == nil {
+		t.Error("expected an error when more than one ServerObserver is given")
 	}
 }
 
@@ -58,24 +167,24 @@ func fetchNegative(ctx context.Context, registration *api.Registration) (*api.Le
 }
 
 // tests for all unary server functions
-// timeout is in microseconds to allow for ease of testing context timeouts against
-// the repo's hardcoded 1 millisecond sleep
+// rpcTimeout is the configured cfg.RPCTimeout under test, and sleep is how
+// long the fake repository functions take to respond; the timeout case sets
+// sleep well above rpcTimeout to force context cancellation.
 func TestUnaryFunctions(t *testing.T) {
 	cases := []struct {
-		valid   bool
-		name    string
-		lease   func(ctx context.Context, lease *api.Lease) error
-		reg     func(ctx context.Context, registration *api.Registration) (*api.Lease, error)
-		timeout int
-		ls      *api.Lease
-		rs      *api.Registration
+		valid      bool
+		name       string
+		negative   bool
+		rpcTimeout time.Duration
+		sleep      time.Duration
+		ls         *api.Lease
+		rs         *api.Registration
 	}{
 		{
-			name:    "positive case",
-			valid:   true,
-			timeout: 5000,
-			lease:   leasePositive,
-			reg:     fetchPositive,
+			name:       "positive case",
+			valid:      true,
+			rpcTimeout: 5 * time.Millisecond,
+			sleep:      time.Microsecond,
 			ls: &api.Lease{
 				Identifier: "foo",
 				Expiration: 1000,
@@ -86,10 +195,9 @@ func TestUnaryFunctions(t *testing.T) {
 			},
 		},
 		{
-			name:    "timeout case",
-			timeout: 1,
-			lease:   leasePositive,
-			reg:     fetchPositive,
+			name:       "timeout case",
+			rpcTimeout: time.Microsecond,
+			sleep:      5 * time.Millisecond,
 			ls: &api.Lease{
 				Identifier: "foo",
 				Expiration: 1000,
@@ -100,10 +208,9 @@ func TestUnaryFunctions(t *testing.T) {
 			},
 		},
 		{
-			name:    "invalid requests",
-			timeout: 5000,
-			lease:   leasePositive,
-			reg:     fetchPositive,
+			name:       "invalid requests",
+			rpcTimeout: 5 * time.Millisecond,
+			sleep:      time.Microsecond,
 			ls: &api.Lease{
 				Identifier: "foo",
 			},
@@ -112,10 +219,9 @@ func TestUnaryFunctions(t *testing.T) {
 			},
 		},
 		{
-			name:    "negative case",
-			timeout: 5000,
-			lease:   leaseNegative,
-			reg:     fetchNegative,
+			name:       "negative case",
+			negative:   true,
+			rpcTimeout: 5 * time.Millisecond,
 			ls: &api.Lease{
 				Identifier: "foo",
 				Expiration: 1000,
@@ -129,20 +235,27 @@ func TestUnaryFunctions(t *testing.T) {
 
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
+			lease := leasePositive(c.sleep)
+			reg := fetchPositive(c.sleep)
+			if c.negative {
+				lease = leaseNegative
+				reg = fetchNegative
+			}
+
 			srv := Server{
-				srv:     &grpc.Server{},
-				ctx:     context.Background(),
-				timeout: time.Duration(c.timeout) * time.Microsecond,
+				srv: &grpc.Server{},
+				ctx: context.Background(),
+				cfg: config.ServerConfig{RPCTimeout: config.Duration{Duration: c.rpcTimeout}},
 				repository: &testRepository{
-					store:  c.lease,
-					fetch:  c.reg,
-					delete: c.lease,
+					store:  lease,
+					fetch:  reg,
+					delete: lease,
 				},
 				observer: NewObserver(ioutil.Discard),
 			}
 
 			t.Run("Register", func(t *testing.T) {
-				ctx, cancel := context.WithTimeout(srv.ctx, srv.timeout)
+				ctx, cancel := context.WithTimeout(srv.ctx, srv.cfg.RPCTimeout.Duration)
 				defer cancel()
 				_, err := srv.Register(ctx, c.rs)
 
@@ -155,7 +268,7 @@ func TestUnaryFunctions(t *testing.T) {
 			})
 
 			t.Run("CheckRegistration", func(t *testing.T) {
-				ctx, cancel := context.WithTimeout(srv.ctx, srv.timeout)
+				ctx, cancel := context.WithTimeout(srv.ctx, srv.cfg.RPCTimeout.Duration)
 				defer cancel()
 				_, err := srv.CheckRegistration(ctx, c.rs)
 
@@ -168,7 +281,7 @@ func TestUnaryFunctions(t *testing.T) {
 			})
 
 			t.Run("Deregister", func(t *testing.T) {
-				ctx, cancel := context.WithTimeout(srv.ctx, srv.timeout)
+				ctx, cancel := context.WithTimeout(srv.ctx, srv.cfg.RPCTimeout.Duration)
 				defer cancel()
 				_, err := srv.Deregister(ctx, c.rs)
 
@@ -0,0 +1,91 @@
+// Package service provides a small lifecycle base, modeled on tendermint's
+// base service, for types that run background goroutines and need
+// coordinated, idempotent start/stop semantics.
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+)
+
+// Service is anything with a coordinated start/stop lifecycle.
+type Service interface {
+	// Start begins the service's background work. It is an error to call
+	// Start more than once.
+	Start(ctx context.Context) error
+	// Stop signals the service to shut down. It is an error to call Stop
+	// more than once.
+	Stop() error
+	// Wait blocks until the service has been stopped.
+	Wait() error
+	// IsRunning reports whether the service has been started and not yet
+	// stopped.
+	IsRunning() bool
+}
+
+// Impl is implemented by the concrete type embedding BaseService, supplying
+// the actual start/stop work.
+type Impl interface {
+	OnStart(ctx context.Context) error
+	OnStop() error
+}
+
+// BaseService implements Service, guarding the start/stop transitions with
+// atomics so that Start and Stop are each safe to call exactly once,
+// delegating the real work to an embedding type's OnStart/OnStop.
+type BaseService struct {
+	name    string
+	impl    Impl
+	started int32
+	stopped int32
+	quit    chan struct{}
+}
+
+// NewBaseService returns a BaseService ready to be embedded by impl. name is
+// used only to make error messages more useful.
+func NewBaseService(name string, impl Impl) *BaseService {
+	return &BaseService{
+		name: name,
+		impl: impl,
+		quit: make(chan struct{}),
+	}
+}
+
+// Start transitions the service into the running state and calls
+// impl.OnStart. It returns an error without calling OnStart if the service
+// was already started.
+func (b *BaseService) Start(ctx context.Context) error {
+	if !atomic.CompareAndSwapInt32(&b.started, 0, 1) {
+		return fmt.Errorf("%s: already started", b.name)
+	}
+	return b.impl.OnStart(ctx)
+}
+
+// Stop transitions the service out of the running state, closes the channel
+// returned by Quit exactly once, and calls impl.OnStop. It returns an error
+// without calling OnStop if the service was already stopped.
+func (b *BaseService) Stop() error {
+	if !atomic.CompareAndSwapInt32(&b.stopped, 0, 1) {
+		return fmt.Errorf("%s: already stopped", b.name)
+	}
+	close(b.quit)
+	return b.impl.OnStop()
+}
+
+// Wait blocks until Stop has been called.
+func (b *BaseService) Wait() error {
+	<-b.quit
+	return nil
+}
+
+// IsRunning reports whether Start has been called and Stop has not.
+func (b *BaseService) IsRunning() bool {
+	return atomic.LoadInt32(&b.started) == 1 && atomic.LoadInt32(&b.stopped) == 0
+}
+
+// Quit returns a channel that is closed when Stop is called, for goroutines
+// started by OnStart to select on.
+func (b *BaseService) Quit() <-chan struct{} {
+	return b.quit
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"go.uber.org/goleak"
+)
+
+type fakeImpl struct {
+	startCalls int
+	stopCalls  int
+	done       chan struct{}
+}
+
+func newFakeImpl() *fakeImpl {
+	return &fakeImpl{done: make(chan struct{})}
+}
+
+func (f *fakeImpl) OnStart(ctx context.Context) error {
+	f.startCalls++
+	go func() {
+		<-ctx.Done()
+		close(f.done)
+	}()
+	return nil
+}
+
+func (f *fakeImpl) OnStop() error {
+	f.stopCalls++
+	return nil
+}
+
+// TestStartStopLifecycle exercises 100 start/stop cycles to prove BaseService
+// leaves no goroutines running after Stop.
+func TestStartStopLifecycle(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	for i := 0; i < 100; i++ {
+		impl := newFakeImpl()
+		base := NewBaseService("fake", impl)
+		ctx, cancel := context.WithCancel(context.Background())
+
+		if err := base.Start(ctx); err != nil {
+			t.Fatalf("iteration %d: unexpected error starting: %s", i, err.Error())
+		}
+		if !base.IsRunning() {
+			t.Fatalf("iteration %d: expected service to be running after Start", i)
+		}
+
+		cancel()
+		if err := base.Stop(); err != nil {
+			t.Fatalf("iteration %d: unexpected error stopping: %s", i, err.Error())
+		}
+		if err := base.Wait(); err != nil {
+			t.Fatalf("iteration %d: unexpected error waiting: %s", i, err.Error())
+		}
+		if base.IsRunning() {
+			t.Fatalf("iteration %d: expected service to not be running after Stop", i)
+		}
+		<-impl.done
+	}
+}
+
+func TestDoubleStartStop(t *testing.T) {
+	impl := newFakeImpl()
+	base := NewBaseService("fake", impl)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := base.Start(ctx); err != nil {
+		t.Fatalf("unexpected error on first Start: %s", err.Error())
+	}
+	if err := base.Start(ctx); err == nil {
+		t.Error("expected error on second Start, got nil")
+	}
+
+	if err := base.Stop(); err != nil {
+		t.Fatalf("unexpected error on first Stop: %s", err.Error())
+	}
+	if err := base.Stop(); err == nil {
+		t.Error("expected error on second Stop, got nil")
+	}
+}
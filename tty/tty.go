@@ -13,20 +13,30 @@ import (
 
 	"github.com/creack/pty"
 	"golang.org/x/term"
+
+	"magnapinna/service"
 )
 
 // TODO: add filtering for repeated bash prompts
 
+// errorBufferSize bounds the Terminal.errors channel so that a burst of
+// poller errors around shutdown can't block OnStop/OnStart goroutines.
+const errorBufferSize = 8
+
 // Terminal is the type responsible for creating and managing ptys, as well
-// as managing and buffering IO for the process running in the pty.
+// as managing and buffering IO for the process running in the pty. It
+// embeds service.BaseService so Start/Stop are safe to call exactly once
+// and coordinate shutdown of the underlying ptys.
 type Terminal struct {
-	stdout   chan []byte
-	stdin    chan []byte
-	ctx      context.Context
-	shell    *exec.Cmd
-	shellout poller
-	shellin  poller
-	errors   chan error
+	*service.BaseService
+	stdout    chan []byte
+	stdin     chan []byte
+	ctx       context.Context
+	cancelCtx context.CancelFunc
+	shell     *exec.Cmd
+	shellout  poller
+	shellin   poller
+	errors    chan error
 }
 
 // poller wraps an open file descriptor, as well as a buffer for IO with the pty.
@@ -70,7 +80,7 @@ func NewTerminal(ctx context.Context, command string, args ...string) (*Terminal
 		return nil, fmt.Errorf("could not set pty %s to raw mode: %w", name, err)
 	}
 
-	return &Terminal{
+	t := &Terminal{
 		shell: cmd,
 		ctx:   ctx,
 		shellout: poller{
@@ -83,7 +93,10 @@ func NewTerminal(ctx context.Context, command string, args ...string) (*Terminal
 		},
 		stdout: make(chan []byte),
 		stdin:  make(chan []byte, 1),
-	}, nil
+		errors: make(chan error, errorBufferSize),
+	}
+	t.BaseService = service.NewBaseService("Terminal", t)
+	return t, nil
 }
 
 // Errors returns a read-only channel to receive errors from the Terminal. Note that
@@ -98,14 +111,35 @@ func (t *Terminal) Out() <-chan []byte {
 	return t.stdout
 }
 
-// Start starts the process passed in to NewTerminal, and begins buffering IO for the process.
-func (t *Terminal) Start() error {
+// OnStart implements service.Impl, starting the process passed in to
+// NewTerminal and the goroutines that buffer its IO. ctx becomes the parent
+// of the pollers' own shutdown signal, derived below so that OnStop can tear
+// them down even if the caller's ctx is never itself cancelled.
+func (t *Terminal) OnStart(ctx context.Context) error {
+	t.ctx, t.cancelCtx = context.WithCancel(ctx)
 	go t.wait()
 	go t.outpoll()
 	go t.inpoll()
 	return nil
 }
 
+// OnStop implements service.Impl, cancelling the pollers' context, killing
+// the underlying process so wait() isn't left blocked on it regardless of
+// whether it reacts to the pty hangup below, and closing the pty file
+// descriptors so outpoll's blocked Read unblocks too.
+func (t *Terminal) OnStop() error {
+	t.cancelCtx()
+	if t.shell.Process != nil {
+		t.shell.Process.Kill()
+	}
+	outErr := t.shellout.handle.Close()
+	inErr := t.shellin.handle.Close()
+	if outErr != nil {
+		return outErr
+	}
+	return inErr
+}
+
 func (t *Terminal) wait() {
 	err := t.shell.Wait()
 	t.errors <- err
@@ -118,45 +152,49 @@ func (t *Terminal) outpoll() {
 	for {
 		select {
 		case <-t.ctx.Done():
-			err := t.shellout.handle.Close()
-			if err != nil {
+			if err := t.shellout.handle.Close(); err != nil {
 				t.errors <- err
 			}
+			return
 		default:
-			n := 0
-			var err error
-			for err == nil {
-				n, err = t.shellout.handle.Read(t.shellout.buffer)
-				t.shellout.data += int64(n)
-				t.stdout <- t.shellout.buffer[:n]
+		}
+
+		n, err := t.shellout.handle.Read(t.shellout.buffer)
+		if n > 0 {
+			t.shellout.data += int64(n)
+			select {
+			case t.stdout <- t.shellout.buffer[:n]:
+			case <-t.ctx.Done():
+				return
 			}
-			if err == io.EOF {
-				continue
+		}
+		if err != nil && err != io.EOF {
+			select {
+			case <-t.ctx.Done():
+				return
+			default:
+				t.errors <- err
 			}
-			t.errors <- err
 		}
 	}
 }
 
+// inpoll writes RunCommand's input to the pty, selecting on t.ctx.Done() as
+// well as t.stdin so it exits once Stop (or the caller's own ctx) signals
+// shutdown, instead of blocking on the channel receive forever.
 func (t *Terminal) inpoll() {
 	for {
-		n := 0
-		var err error
-		for err == nil {
-			b := <-t.stdin
+		select {
+		case <-t.ctx.Done():
+			return
+		case b := <-t.stdin:
 			for _, c := range b {
-				err = tiocsti(t.shellin.handle, c)
-				if err != nil {
+				if err := tiocsti(t.shellin.handle, c); err != nil {
 					t.errors <- fmt.Errorf("%w: %s", ErrPtsWrite, err.Error())
 				}
-				n++
 			}
-			t.shellout.data += int64(n)
-		}
-		if err == io.EOF {
-			continue
+			t.shellout.data += int64(len(b))
 		}
-		t.errors <- err
 	}
 }
 
@@ -4,6 +4,8 @@ import (
 	"context"
 	"testing"
 	"time"
+
+	"go.uber.org/goleak"
 )
 
 // TODO use testify and split into separate tests
@@ -40,7 +42,7 @@ func TestRunCommandWithCancel(t *testing.T) {
 			if err != nil {
 				t.Fatalf("failed to create Terminal: %s", err.Error())
 			}
-			term.Start()
+			term.Start(ctx)
 			var strout []string
 
 			out := term.Out()
@@ -70,7 +72,7 @@ func TestRunCommandWithCancel(t *testing.T) {
 
 			select {
 			case err = <-errs:
-				t.Errorf("unexpected error during run/cancel: %w", err)
+				t.Errorf("unexpected error during run/cancel: %v", err)
 			default:
 				// empty case since this is expected for the test
 			}
@@ -82,3 +84,26 @@ func TestRunCommandWithCancel(t *testing.T) {
 		})
 	}
 }
+
+// TestTerminalStartStopNoLeaks proves that Stop alone - with no cancellation
+// of the ctx passed to Start - unblocks both outpoll and inpoll, leaving no
+// goroutines running behind.
+func TestTerminalStartStopNoLeaks(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	for i := 0; i < 100; i++ {
+		term, err := NewTerminal(context.Background(), "sleep", "5")
+		if err != nil {
+			t.Fatalf("iteration %d: failed to create Terminal: %s", i, err.Error())
+		}
+		if err := term.Start(context.Background()); err != nil {
+			t.Fatalf("iteration %d: failed to start Terminal: %s", i, err.Error())
+		}
+		if err := term.Stop(); err != nil {
+			t.Fatalf("iteration %d: failed to stop Terminal: %s", i, err.Error())
+		}
+		if err := term.Wait(); err != nil {
+			t.Fatalf("iteration %d: failed to wait: %s", i, err.Error())
+		}
+	}
+}